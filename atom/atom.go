@@ -0,0 +1,178 @@
+// Package atom reads and writes Atom feeds (RFC 4287) of ranked restaurants,
+// so readers can subscribe to the restaurants that are new in each run.
+//
+// Each entry is tagged with a category naming the run that added it, which
+// lets a feed be updated run by run and trimmed to its most recent runs.
+package atom
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+// RunScheme is the scheme of the category that names the run an entry was
+// added by.
+const RunScheme = "urn:reddit-to-gmap:run"
+
+// Feed is an Atom feed.
+type Feed struct {
+	XMLName xml.Name  `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string    `xml:"id"`
+	Title   string    `xml:"title"`
+	Updated time.Time `xml:"updated"`
+	Author  Person    `xml:"author"`
+	Links   []Link    `xml:"link,omitempty"`
+	Entries []Entry   `xml:"entry"`
+}
+
+// Person is the author of a feed.
+type Person struct {
+	Name string `xml:"name"`
+}
+
+// Entry is a single item of a feed.
+type Entry struct {
+	ID         string     `xml:"id"`
+	Title      string     `xml:"title"`
+	Updated    time.Time  `xml:"updated"`
+	Links      []Link     `xml:"link,omitempty"`
+	Categories []Category `xml:"category,omitempty"`
+	Content    Content    `xml:"content"`
+}
+
+// Link is a link from a feed or entry.
+type Link struct {
+	Rel   string `xml:"rel,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Title string `xml:"title,attr,omitempty"`
+}
+
+// Category is a label on an entry.
+type Category struct {
+	Scheme string `xml:"scheme,attr,omitempty"`
+	Term   string `xml:"term,attr"`
+	Label  string `xml:"label,attr,omitempty"`
+}
+
+// Content is the body of an entry. Type is "html" when Body holds HTML.
+type Content struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+// New returns an empty feed. The ID should stay the same across runs, so
+// readers see a single feed.
+func New(id, title string) *Feed {
+	return &Feed{ID: id, Title: title, Author: Person{Name: "reddit-to-gmap"}}
+}
+
+// Read parses a feed.
+func Read(r io.Reader) (*Feed, error) {
+	var feed Feed
+	if err := xml.NewDecoder(r).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("error parsing Atom feed: %v", err)
+	}
+	return &feed, nil
+}
+
+// RestaurantEntry returns the entry of a restaurant ranked by a run. It links
+// to the restaurant's Google Maps page and the Reddit post it was found in.
+func RestaurantEntry(runID string, rank int, r maps.Restaurant, updated time.Time) Entry {
+	data := r.GoogleMapsData
+	name := data.Name
+	if name == "" {
+		name = r.Name
+	}
+
+	entry := Entry{
+		ID:         fmt.Sprintf("%s:%s:%s", RunScheme, url.PathEscape(runID), url.PathEscape(strings.ToLower(name))),
+		Title:      fmt.Sprintf("#%d %s", rank, name),
+		Updated:    updated,
+		Categories: []Category{{Scheme: RunScheme, Term: runID}},
+	}
+	if data.Type != "" {
+		entry.Categories = append(entry.Categories, Category{Term: data.Type})
+	}
+	if data.GoogleMapsUrl != "" {
+		entry.Links = append(entry.Links, Link{Rel: "alternate", Href: data.GoogleMapsUrl, Title: "Google Maps"})
+	}
+	if r.RedditUrl != "" {
+		entry.Links = append(entry.Links, Link{Rel: "related", Href: r.RedditUrl, Title: "Reddit post"})
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "<p>#%d", rank)
+	if data.Type != "" {
+		fmt.Fprintf(&body, " · %s", html.EscapeString(data.Type))
+	}
+	fmt.Fprintf(&body, "<br>Rating: %.1f (%d reviews)<br>Upvotes: %d</p>", data.Rating, data.UserRatingCount, r.Upvotes)
+	var links []string
+	if data.GoogleMapsUrl != "" {
+		links = append(links, fmt.Sprintf(`<a href="%s">Google Maps</a>`, html.EscapeString(data.GoogleMapsUrl)))
+	}
+	if r.RedditUrl != "" {
+		links = append(links, fmt.Sprintf(`<a href="%s">Reddit post</a>`, html.EscapeString(r.RedditUrl)))
+	}
+	if len(links) > 0 {
+		fmt.Fprintf(&body, "<p>%s</p>", strings.Join(links, " · "))
+	}
+	entry.Content = Content{Type: "html", Body: body.String()}
+	return entry
+}
+
+// RunID returns the run that added an entry, or "" if it is not known.
+func (e *Entry) RunID() string {
+	for _, c := range e.Categories {
+		if c.Scheme == RunScheme {
+			return c.Term
+		}
+	}
+	return ""
+}
+
+// AddRun puts a run's entries at the top of the feed, replacing any the run
+// added before, and keeps only the entries of the most recent keepRuns runs
+// (all of them if keepRuns is 0).
+func (f *Feed) AddRun(runID string, entries []Entry, updated time.Time, keepRuns int) {
+	kept := slices.DeleteFunc(f.Entries, func(e Entry) bool {
+		return e.RunID() == runID
+	})
+	f.Entries = append(slices.Clone(entries), kept...)
+	f.Updated = updated
+
+	if keepRuns <= 0 {
+		return
+	}
+	var runs []string
+	f.Entries = slices.DeleteFunc(f.Entries, func(e Entry) bool {
+		id := e.RunID()
+		if !slices.Contains(runs, id) {
+			runs = append(runs, id)
+		}
+		return len(runs) > keepRuns
+	})
+}
+
+// Write writes the feed as XML.
+func (f *Feed) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("error writing Atom feed: %v", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(f); err != nil {
+		return fmt.Errorf("error writing Atom feed: %v", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("error writing Atom feed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package atom_test
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/atom"
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+var updated = time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)
+
+func restaurant(name string) maps.Restaurant {
+	return maps.Restaurant{Name: name, GoogleMapsData: maps.GoogleMapsData{Name: name}}
+}
+
+// entries returns the entries a run adds for the named restaurants.
+func entries(runID string, names ...string) []atom.Entry {
+	result := make([]atom.Entry, len(names))
+	for i, name := range names {
+		result[i] = atom.RestaurantEntry(runID, i+1, restaurant(name), updated)
+	}
+	return result
+}
+
+func titles(feed *atom.Feed) []string {
+	result := make([]string, len(feed.Entries))
+	for i, e := range feed.Entries {
+		result[i] = e.RunID() + " " + e.Title
+	}
+	return result
+}
+
+func TestRestaurantEntry(t *testing.T) {
+	r := maps.Restaurant{
+		Name:      "Joe's",
+		Upvotes:   515,
+		RedditUrl: "https://www.reddit.com/r/foodnyc/comments/a1/joes/",
+		GoogleMapsData: maps.GoogleMapsData{
+			Name:            "Joe's Pizza",
+			Type:            "Pizza & Slices",
+			Rating:          4.5,
+			UserRatingCount: 2271,
+			GoogleMapsUrl:   "https://www.google.com/maps/search/?api=1&query=Joe%27s",
+		},
+	}
+	e := atom.RestaurantEntry("foodnyc-20260201-090000", 3, r, updated)
+
+	if e.ID != "urn:reddit-to-gmap:run:foodnyc-20260201-090000:joe%27s%20pizza" {
+		t.Errorf("ID = %q", e.ID)
+	}
+	if e.Title != "#3 Joe's Pizza" || e.RunID() != "foodnyc-20260201-090000" {
+		t.Errorf("entry = %+v", e)
+	}
+	for _, want := range []string{
+		"#3 · Pizza &amp; Slices",
+		"Rating: 4.5 (2271 reviews)<br>Upvotes: 515",
+		`<a href="https://www.google.com/maps/search/?api=1&amp;query=Joe%27s">Google Maps</a>`,
+	} {
+		if !strings.Contains(e.Content.Body, want) {
+			t.Errorf("content = %q, want it to contain %q", e.Content.Body, want)
+		}
+	}
+
+	// The ID stays the same at another rank or time, and doesn't depend on
+	// case, so a rerun replaces its entries rather than adding new ones
+	again := atom.RestaurantEntry("foodnyc-20260201-090000", 1, restaurant("JOE'S PIZZA"), updated.Add(time.Hour))
+	if again.ID != e.ID {
+		t.Errorf("ID of the same restaurant = %q, want %q", again.ID, e.ID)
+	}
+	if other := atom.RestaurantEntry("foodnyc-20260301-090000", 3, r, updated); other.ID == e.ID {
+		t.Errorf("ID of another run = %q, the same as the first", other.ID)
+	}
+
+	// Without a Places name, the Reddit name is used
+	if e := atom.RestaurantEntry("run", 1, maps.Restaurant{Name: "Duzan"}, updated); e.Title != "#1 Duzan" {
+		t.Errorf("Title = %q, want #1 Duzan", e.Title)
+	}
+}
+
+func TestAddRun(t *testing.T) {
+	feed := atom.New("urn:test", "Test")
+	feed.AddRun("run1", entries("run1", "A", "B"), updated, 2)
+	feed.AddRun("run2", entries("run2", "C"), updated, 2)
+	if want := []string{"run2 #1 C", "run1 #1 A", "run1 #2 B"}; !slices.Equal(titles(feed), want) {
+		t.Errorf("entries = %q, want %q", titles(feed), want)
+	}
+
+	// A third run pushes out the first
+	feed.AddRun("run3", entries("run3", "D", "E"), updated, 2)
+	if want := []string{"run3 #1 D", "run3 #2 E", "run2 #1 C"}; !slices.Equal(titles(feed), want) {
+		t.Errorf("entries = %q, want %q", titles(feed), want)
+	}
+
+	// Rerunning a run replaces its entries in place of adding a run
+	feed.AddRun("run3", entries("run3", "F"), updated, 2)
+	if want := []string{"run3 #1 F", "run2 #1 C"}; !slices.Equal(titles(feed), want) {
+		t.Errorf("entries = %q, want %q", titles(feed), want)
+	}
+
+	// A run with no new restaurants leaves no trace, so it keeps the others
+	feed.AddRun("run4", nil, updated, 2)
+	if want := []string{"run3 #1 F", "run2 #1 C"}; !slices.Equal(titles(feed), want) {
+		t.Errorf("entries = %q, want %q", titles(feed), want)
+	}
+}
+
+func TestAddRunKeepsAll(t *testing.T) {
+	feed := atom.New("urn:test", "Test")
+	for _, run := range []string{"run1", "run2", "run3"} {
+		feed.AddRun(run, entries(run, "A"), updated, 0)
+	}
+	if len(feed.Entries) != 3 {
+		t.Errorf("got %d entries, want all 3", len(feed.Entries))
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	feed := atom.New("urn:test", "New top restaurants on r/foodnyc")
+	feed.AddRun("run1", entries("run1", "A & B", "<C>"), updated, 0)
+
+	var b bytes.Buffer
+	if err := feed.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	read, err := atom.Read(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.ID != feed.ID || read.Title != feed.Title || !read.Updated.Equal(updated) {
+		t.Errorf("feed = %+v", read)
+	}
+	if !slices.Equal(titles(read), titles(feed)) {
+		t.Errorf("entries = %q, want %q", titles(read), titles(feed))
+	}
+	if read.Entries[0].Content != feed.Entries[0].Content || read.Entries[0].ID != feed.Entries[0].ID {
+		t.Errorf("entry = %+v, want %+v", read.Entries[0], feed.Entries[0])
+	}
+}
@@ -59,6 +59,13 @@ func ReadFromCache(subreddit string) (*Cache, error) {
 	return &cache, nil
 }
 
+func RemoveFromCache(subreddit string) error {
+	if err := os.Remove(GetCachePath(subreddit)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing cache file: %v", err)
+	}
+	return nil
+}
+
 func CacheExists(subreddit string) bool {
 	_, err := os.Stat(GetCachePath(subreddit))
 	return err == nil
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/csv"
+	"github.com/tonyjhuang/reddit-to-gmap/filter"
+	"github.com/tonyjhuang/reddit-to-gmap/job"
+	"github.com/tonyjhuang/reddit-to-gmap/notify"
+	"github.com/tonyjhuang/reddit-to-gmap/reddit"
+)
+
+// DefaultPath is where jobs are read from when --config is not set.
+const DefaultPath = "jobs.json"
+
+// File is the on-disk job configuration.
+type File struct {
+	Jobs []Job `json:"jobs"`
+}
+
+// Job is a named, reusable set of run parameters.
+type Job struct {
+	Name      string `json:"name"`
+	Subreddit string `json:"subreddit"`
+	NumPosts  int    `json:"num_posts,omitempty"`
+	TimeRange string `json:"time_range,omitempty"`
+	// Since, Until and Month fetch posts by submission date; see --month.
+	Since         string `json:"since,omitempty"`
+	Until         string `json:"until,omitempty"`
+	Month         string `json:"month,omitempty"`
+	Listing       string `json:"listing,omitempty"`
+	Query         string `json:"query,omitempty"`
+	SearchSort    string `json:"search_sort,omitempty"`
+	MapsQueryHint string `json:"maps_query_hint,omitempty"`
+	NumOutput     int    `json:"num_output,omitempty"`
+	// Boundaries, Neighborhoods and Boroughs assign restaurants to areas and
+	// filter them; see --boundaries.
+	Boundaries    []string `json:"boundaries,omitempty"`
+	Neighborhoods []string `json:"neighborhoods,omitempty"`
+	Boroughs      []string `json:"boroughs,omitempty"`
+	// OutputDir, Filename and Formats control where outputs are written; see
+	// the output package for the filename placeholders.
+	OutputDir string   `json:"output_dir,omitempty"`
+	Filename  string   `json:"filename,omitempty"`
+	Formats   []string `json:"formats,omitempty"`
+	CSV       CSV      `json:"csv,omitempty"`
+	// GroupBy groups the Markdown output; see --group-by.
+	GroupBy string `json:"group_by,omitempty"`
+	// FeedRuns is the number of runs the Atom feed keeps; see --feed-runs.
+	FeedRuns int `json:"feed_runs,omitempty"`
+	// Filter drops posts before they are sent to Gemini.
+	Filter filter.Config `json:"filter,omitempty"`
+	// Stages lists the pipeline stages to run, in order; see --stages.
+	Stages []string `json:"stages,omitempty"`
+	// Schedule is a cron expression for running the job with the schedule
+	// command, e.g. "0 0 1 * *" for midnight on the 1st of every month.
+	Schedule string `json:"schedule,omitempty"`
+	// Notify sends a summary of each run to webhooks.
+	Notify Notify `json:"notify,omitzero"`
+}
+
+// Notify configures the notifications sent after a job's runs. Webhook URLs
+// in a config file may refer to environment variables, e.g.
+// "$SLACK_WEBHOOK_URL", to keep them out of the file; Load expands them.
+type Notify struct {
+	// Top is the number of top restaurants in a summary.
+	Top      int              `json:"top,omitempty"`
+	Webhooks []notify.Webhook `json:"webhooks,omitempty"`
+}
+
+// Sinks returns the notification sinks of the job's webhooks.
+func (n Notify) Sinks() ([]notify.Sink, error) {
+	var sinks []notify.Sink
+	for _, webhook := range n.Webhooks {
+		if err := webhook.Validate(); err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, &webhook)
+	}
+	return sinks, nil
+}
+
+// CSV configures the CSV output of a job. When Columns is set it takes
+// precedence over Schema.
+type CSV struct {
+	Schema  string       `json:"schema,omitempty"`
+	Columns []csv.Column `json:"columns,omitempty"`
+}
+
+// Load reads a job configuration file.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %v", err)
+	}
+
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %v", path, err)
+	}
+	// Only trusted config files may refer to the environment; jobs from
+	// elsewhere, such as the HTTP API, keep their URLs as they are
+	for i := range file.Jobs {
+		for k, webhook := range file.Jobs[i].Notify.Webhooks {
+			file.Jobs[i].Notify.Webhooks[k].URL = os.ExpandEnv(webhook.URL)
+		}
+	}
+	return &file, nil
+}
+
+// Job returns the job with the given name.
+func (f *File) Job(name string) (*Job, error) {
+	for i := range f.Jobs {
+		if f.Jobs[i].Name == name {
+			return &f.Jobs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no job named %q in config", name)
+}
+
+// Flags returns the job's settings keyed by command-line flag name. Unset
+// settings are omitted so flag defaults still apply.
+func (j *Job) Flags() map[string]string {
+	flags := make(map[string]string)
+	set := func(name, value string) {
+		if value != "" {
+			flags[name] = value
+		}
+	}
+	setInt := func(name string, value int) {
+		if value != 0 {
+			flags[name] = strconv.Itoa(value)
+		}
+	}
+
+	set("subreddit", j.Subreddit)
+	setInt("num-posts", j.NumPosts)
+	set("time-range", j.TimeRange)
+	set("since", j.Since)
+	set("until", j.Until)
+	set("month", j.Month)
+	set("listing", j.Listing)
+	set("query", j.Query)
+	set("search-sort", j.SearchSort)
+	set("maps-query-hint", j.MapsQueryHint)
+	setInt("num-output", j.NumOutput)
+	set("boundaries", strings.Join(j.Boundaries, ","))
+	set("neighborhood", strings.Join(j.Neighborhoods, ","))
+	set("borough", strings.Join(j.Boroughs, ","))
+	set("output-dir", j.OutputDir)
+	set("filename", j.Filename)
+	set("format", strings.Join(j.Formats, ","))
+	set("csv-schema", j.CSV.Schema)
+	set("group-by", j.GroupBy)
+	setInt("feed-runs", j.FeedRuns)
+	set("stages", strings.Join(j.Stages, ","))
+
+	f := j.Filter
+	setInt("min-score", f.MinScore)
+	setInt("min-comments", f.MinComments)
+	if f.MinUpvoteRatio != 0 {
+		flags["min-upvote-ratio"] = strconv.FormatFloat(f.MinUpvoteRatio, 'f', -1, 64)
+	}
+	set("flair-allow", strings.Join(f.FlairAllow, ","))
+	set("flair-deny", strings.Join(f.FlairDeny, ","))
+	set("title-match", f.TitleMatch)
+	set("title-exclude", f.TitleExclude)
+	setInt("min-selftext-length", f.MinSelftextLength)
+	if f.ExcludeRemoved != nil {
+		flags["exclude-removed"] = strconv.FormatBool(*f.ExcludeRemoved)
+	}
+	if f.ExcludeNSFW {
+		flags["exclude-nsfw"] = "true"
+	}
+	return flags
+}
+
+// Options returns the job's settings as options for job.Run, resolving
+// relative dates such as "month": "last" against now. Credentials, run state
+// and caching are left for the caller to set.
+func (j *Job) Options(now time.Time) (job.Options, error) {
+	since, until, err := job.ParseWindow(j.Since, j.Until, j.Month, now)
+	if err != nil {
+		return job.Options{}, fmt.Errorf("invalid job %q: %v", j.Name, err)
+	}
+	sinks, err := j.Notify.Sinks()
+	if err != nil {
+		return job.Options{}, fmt.Errorf("invalid job %q: %v", j.Name, err)
+	}
+	return job.Options{
+		Name:      j.Name,
+		Subreddit: j.Subreddit,
+		NumPosts:  j.NumPosts,
+		Listing: reddit.Listing{
+			Sort:       j.Listing,
+			TimeRange:  j.TimeRange,
+			Query:      j.Query,
+			SearchSort: j.SearchSort,
+		},
+		Since:         since,
+		Until:         until,
+		Filter:        j.Filter,
+		MapsQueryHint: j.MapsQueryHint,
+		NumOutput:     j.NumOutput,
+		Boundaries:    j.Boundaries,
+		Neighborhoods: j.Neighborhoods,
+		Boroughs:      j.Boroughs,
+		Stages:        j.Stages,
+		OutputDir:     j.OutputDir,
+		Filename:      j.Filename,
+		Formats:       j.Formats,
+		CSVSchema:     j.CSV.Schema,
+		CSVColumns:    j.CSV.Columns,
+		GroupBy:       j.GroupBy,
+		FeedRuns:      j.FeedRuns,
+		Notify:        sinks,
+		NotifyTop:     j.Notify.Top,
+	}, nil
+}
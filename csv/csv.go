@@ -3,55 +3,53 @@ package csv
 import (
 	"encoding/csv"
 	"fmt"
-	"os"
+	"io"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
 )
 
-// Writer handles writing data to CSV files
+// Writer handles writing data in CSV format
 type Writer struct {
-	file   *os.File
 	writer *csv.Writer
-	path   string
 }
 
-const outputDir = "out"
-
-// NewWriter creates a new CSV writer for the given file name
-func NewWriter(filename string) (*Writer, error) {
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return nil, fmt.Errorf("error creating output directory: %v", err)
-	}
-
-	// Create the CSV file
-	file, err := os.Create(outputDir + "/" + filename)
-	if err != nil {
-		return nil, fmt.Errorf("error creating CSV file: %v", err)
-	}
-
+// NewWriter creates a new CSV writer that writes to w
+func NewWriter(w io.Writer) *Writer {
 	return &Writer{
-		file:   file,
-		writer: csv.NewWriter(file),
-		path:   outputDir + "/" + filename,
-	}, nil
+		writer: csv.NewWriter(w),
+	}
 }
 
-// WriteHeader writes the header row to the CSV file
+// WriteHeader writes the header row
 func (w *Writer) WriteHeader(header []string) error {
 	return w.writer.Write(header)
 }
 
-// WriteRow writes a row of data to the CSV file
+// WriteRow writes a row of data
 func (w *Writer) WriteRow(row []string) error {
 	return w.writer.Write(row)
 }
 
-// Close closes the CSV file and flushes any buffered data
-func (w *Writer) Close() error {
-	w.writer.Flush()
-	return w.file.Close()
+// WriteRestaurants writes the layout's header followed by one row per
+// restaurant, ranked in slice order.
+func (w *Writer) WriteRestaurants(layout Layout, restaurants []maps.Restaurant) error {
+	if err := w.WriteHeader(layout.Header()); err != nil {
+		return fmt.Errorf("error writing CSV header: %v", err)
+	}
+	for i, restaurant := range restaurants {
+		row, err := layout.Row(i+1, restaurant)
+		if err != nil {
+			return err
+		}
+		if err := w.WriteRow(row); err != nil {
+			return fmt.Errorf("error writing CSV row: %v", err)
+		}
+	}
+	return nil
 }
 
-// Path returns the path of the CSV file
-func (w *Writer) Path() string {
-	return w.path
+// Flush writes any buffered data to the underlying writer
+func (w *Writer) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
 }
@@ -0,0 +1,156 @@
+package csv_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tonyjhuang/reddit-to-gmap/csv"
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+func write(t *testing.T, schema csv.Schema, restaurants []maps.Restaurant) string {
+	t.Helper()
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.WriteRestaurants(schema, restaurants); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestStructuredRoundTrip(t *testing.T) {
+	restaurants := []maps.Restaurant{
+		{
+			Name:         "Joe's",
+			Upvotes:      515,
+			RedditUrl:    "https://www.reddit.com/r/foodnyc/comments/a1/joes/",
+			PostTitle:    "Joe's, a \"classic\", still great",
+			Neighborhood: "Greenwich Village",
+			Borough:      "Manhattan",
+			Dishes:       []string{"cheese slice", "grandma pie"},
+			Mentions:     3,
+			GoogleMapsData: maps.GoogleMapsData{
+				Name:            "Joe's Pizza",
+				Latitude:        40.730599,
+				Longitude:       -73.989012,
+				Rating:          4.5,
+				UserRatingCount: 2271,
+				GoogleMapsUrl:   "https://www.google.com/maps/search/?api=1&query=Joe%27s&query_place_id=ChIJabc",
+				Type:            "Pizza restaurant",
+			},
+		},
+		{
+			// No Google Maps match and negative upvotes
+			Name:    "Somewhere, Queens",
+			Upvotes: -2,
+		},
+	}
+
+	data := write(t, csv.SchemaStructured, restaurants)
+	if header, _, _ := strings.Cut(data, "\n"); !strings.HasPrefix(header, "rank,name,") {
+		t.Fatalf("header = %q", header)
+	}
+
+	got, err := csv.ReadRestaurants(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, restaurants) {
+		t.Errorf("round trip =\n%+v\nwant\n%+v", got, restaurants)
+	}
+}
+
+func TestLegacyRoundTrip(t *testing.T) {
+	// The legacy layout has no Reddit-side name, and rounds ratings to one
+	// decimal, so these restaurants fit in it exactly
+	restaurants := []maps.Restaurant{
+		{
+			Name:      "Duzan",
+			Upvotes:   515,
+			RedditUrl: "https://www.reddit.com/r/foodnyc/comments/b2/duzan/",
+			GoogleMapsData: maps.GoogleMapsData{
+				Name:            "Duzan",
+				Latitude:        40.767001,
+				Longitude:       -73.921002,
+				Rating:          4.3,
+				UserRatingCount: 2271,
+				GoogleMapsUrl:   "https://www.google.com/maps/search/?api=1&query=Duzan&query_place_id=ChIJdef",
+				Type:            "Middle Eastern",
+			},
+		},
+		{
+			// A name that looks like the packed column itself
+			Name:    "Bar (#1, 2 upvotes)",
+			Upvotes: 7,
+			GoogleMapsData: maps.GoogleMapsData{
+				Name:      "Bar (#1, 2 upvotes)",
+				Latitude:  40.7,
+				Longitude: -73.9,
+			},
+		},
+	}
+
+	data := write(t, csv.SchemaLegacy, restaurants)
+	if header, _, _ := strings.Cut(data, "\n"); !strings.HasPrefix(header, "Name,Type,") {
+		t.Fatalf("header = %q", header)
+	}
+
+	got, err := csv.ReadRestaurants(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, restaurants) {
+		t.Errorf("round trip =\n%+v\nwant\n%+v", got, restaurants)
+	}
+}
+
+func TestReadRestaurantsDetectsSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []maps.Restaurant
+		wantErr bool
+	}{
+		{
+			name: "legacy",
+			data: "Name,Type,Google Maps url,Google Maps rating,Reddit url,Lat,Lng\n\"A (#1, 9 upvotes)\",,,,,1.5,2.5\n",
+			want: []maps.Restaurant{{Name: "A", Upvotes: 9, GoogleMapsData: maps.GoogleMapsData{Name: "A", Latitude: 1.5, Longitude: 2.5}}},
+		},
+		{
+			// Files written before the borough column was added
+			name: "v2 without borough",
+			data: "rank,name,upvotes\n1,A,9\n",
+			want: []maps.Restaurant{{Name: "A", Upvotes: 9}},
+		},
+		{
+			name: "empty",
+			data: "",
+		},
+		{
+			name:    "unknown header",
+			data:    "title,score\nA,9\n",
+			wantErr: true,
+		},
+		{
+			name:    "bad number",
+			data:    "rank,name,upvotes\n1,A,lots\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := csv.ReadRestaurants(strings.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadRestaurants() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package csv
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+var (
+	// nameColumnPattern matches the legacy Name column, e.g. "Duzan (#2, 515 upvotes)".
+	nameColumnPattern = regexp.MustCompile(`^(.*) \(#(\d+), (-?\d+) upvotes\)$`)
+	// ratingColumnPattern matches the legacy rating column, e.g. "4.3 (2271 reviews)".
+	ratingColumnPattern = regexp.MustCompile(`^([\d.]+) \((\d+) reviews\)$`)
+)
+
+// ReadFile reads a CSV file previously written by the tool.
+func ReadFile(path string) ([]maps.Restaurant, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening CSV file: %v", err)
+	}
+	defer file.Close()
+
+	restaurants, err := ReadRestaurants(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	return restaurants, nil
+}
+
+// ReadRestaurants parses CSV data written in either schema and returns one
+// restaurant per row, in file (rank) order. The schema is detected from the header.
+func ReadRestaurants(r io.Reader) ([]maps.Restaurant, error) {
+	reader := csv.NewReader(r)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	schema, err := detectSchema(records[0])
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	row := record{columns: columns}
+
+	restaurants := make([]maps.Restaurant, 0, len(records)-1)
+	for i, fields := range records[1:] {
+		row.fields = fields
+
+		var restaurant maps.Restaurant
+		if schema == SchemaStructured {
+			restaurant, err = parseStructured(row)
+		} else {
+			restaurant, err = parseLegacy(row)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", i+2, err)
+		}
+		restaurants = append(restaurants, restaurant)
+	}
+
+	return restaurants, nil
+}
+
+// record looks up fields of a CSV row by column name.
+type record struct {
+	columns map[string]int
+	fields  []string
+}
+
+func (r record) get(name string) string {
+	i, ok := r.columns[name]
+	if !ok || i >= len(r.fields) {
+		return ""
+	}
+	return strings.TrimSpace(r.fields[i])
+}
+
+func (r record) getInt(name string) (int, error) {
+	value := r.get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return n, nil
+}
+
+func (r record) getFloat(name string) (float64, error) {
+	value := r.get(name)
+	if value == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return f, nil
+}
+
+// parseLegacy unpacks a row in the legacy layout. The Reddit-side name is not
+// stored in this layout, so the Google Maps name is used for both.
+func parseLegacy(row record) (maps.Restaurant, error) {
+	var restaurant maps.Restaurant
+	var err error
+
+	name := row.get("Name")
+	if m := nameColumnPattern.FindStringSubmatch(name); m != nil {
+		name = m[1]
+		restaurant.Upvotes, _ = strconv.Atoi(m[3])
+	}
+	restaurant.Name = name
+	restaurant.RedditUrl = row.get("Reddit url")
+	restaurant.GoogleMapsData.Name = name
+	restaurant.GoogleMapsData.Type = row.get("Type")
+	restaurant.GoogleMapsData.GoogleMapsUrl = row.get("Google Maps url")
+
+	if rating := row.get("Google Maps rating"); rating != "" {
+		m := ratingColumnPattern.FindStringSubmatch(rating)
+		if m == nil {
+			return restaurant, fmt.Errorf("unrecognized rating %q", rating)
+		}
+		restaurant.GoogleMapsData.Rating, _ = strconv.ParseFloat(m[1], 64)
+		restaurant.GoogleMapsData.UserRatingCount, _ = strconv.Atoi(m[2])
+	}
+
+	if restaurant.GoogleMapsData.Latitude, err = row.getFloat("Lat"); err != nil {
+		return restaurant, err
+	}
+	if restaurant.GoogleMapsData.Longitude, err = row.getFloat("Lng"); err != nil {
+		return restaurant, err
+	}
+	return restaurant, nil
+}
+
+// parseStructured reads a row in the structured (v2) layout.
+func parseStructured(row record) (maps.Restaurant, error) {
+	var restaurant maps.Restaurant
+	var err error
+
+	restaurant.Name = row.get("name")
+	restaurant.RedditUrl = row.get("reddit_url")
+	restaurant.PostTitle = row.get("post_title")
+	restaurant.Neighborhood = row.get("neighborhood")
+	restaurant.Borough = row.get("borough")
+	if dishes := row.get("dishes"); dishes != "" {
+		restaurant.Dishes = strings.Split(dishes, dishSeparator)
+	}
+	restaurant.GoogleMapsData.Name = row.get("maps_name")
+	restaurant.GoogleMapsData.Type = row.get("type")
+	restaurant.GoogleMapsData.GoogleMapsUrl = row.get("google_maps_url")
+
+	if restaurant.Upvotes, err = row.getInt("upvotes"); err != nil {
+		return restaurant, err
+	}
+	if restaurant.Mentions, err = row.getInt("mentions"); err != nil {
+		return restaurant, err
+	}
+	if restaurant.GoogleMapsData.Rating, err = row.getFloat("rating"); err != nil {
+		return restaurant, err
+	}
+	if restaurant.GoogleMapsData.UserRatingCount, err = row.getInt("user_rating_count"); err != nil {
+		return restaurant, err
+	}
+	if restaurant.GoogleMapsData.Latitude, err = row.getFloat("latitude"); err != nil {
+		return restaurant, err
+	}
+	if restaurant.GoogleMapsData.Longitude, err = row.getFloat("longitude"); err != nil {
+		return restaurant, err
+	}
+	return restaurant, nil
+}
@@ -0,0 +1,91 @@
+package csv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+// Schema identifies a CSV column layout.
+type Schema string
+
+const (
+	// SchemaLegacy is the Google My Maps friendly layout, which packs rank and
+	// upvotes into the Name column and the review count into the rating column.
+	SchemaLegacy Schema = "legacy"
+	// SchemaStructured is version 2 of the output format, with one value per column.
+	SchemaStructured Schema = "v2"
+)
+
+// dishSeparator joins the dishes column of the structured schema.
+const dishSeparator = "; "
+
+var (
+	legacyHeader     = []string{"Name", "Type", "Google Maps url", "Google Maps rating", "Reddit url", "Lat", "Lng"}
+	structuredHeader = []string{"rank", "name", "maps_name", "type", "upvotes", "rating", "user_rating_count", "google_maps_url", "place_id", "reddit_url", "post_title", "neighborhood", "dishes", "mentions", "latitude", "longitude", "borough"}
+)
+
+// ParseSchema validates a schema name.
+func ParseSchema(name string) (Schema, error) {
+	switch Schema(name) {
+	case SchemaLegacy, SchemaStructured:
+		return Schema(name), nil
+	default:
+		return "", fmt.Errorf("unknown CSV schema %q (expected %s or %s)", name, SchemaLegacy, SchemaStructured)
+	}
+}
+
+// Header returns the header row for the schema.
+func (s Schema) Header() []string {
+	if s == SchemaStructured {
+		return structuredHeader
+	}
+	return legacyHeader
+}
+
+// Row formats a restaurant at the given 1-based rank as a row in the schema.
+func (s Schema) Row(rank int, r maps.Restaurant) ([]string, error) {
+	if s == SchemaStructured {
+		return []string{
+			strconv.Itoa(rank),
+			r.Name,
+			r.GoogleMapsData.Name,
+			r.GoogleMapsData.Type,
+			strconv.Itoa(r.Upvotes),
+			strconv.FormatFloat(r.GoogleMapsData.Rating, 'f', -1, 64),
+			strconv.Itoa(r.GoogleMapsData.UserRatingCount),
+			r.GoogleMapsData.GoogleMapsUrl,
+			r.GoogleMapsData.PlaceID(),
+			r.RedditUrl,
+			r.PostTitle,
+			r.Neighborhood,
+			strings.Join(r.Dishes, dishSeparator),
+			strconv.Itoa(r.Mentions),
+			fmt.Sprintf("%.6f", r.GoogleMapsData.Latitude),
+			fmt.Sprintf("%.6f", r.GoogleMapsData.Longitude),
+			r.Borough,
+		}, nil
+	}
+	return []string{
+		fmt.Sprintf("%s (#%d, %d upvotes)", r.GoogleMapsData.Name, rank, r.Upvotes),
+		r.GoogleMapsData.Type,
+		r.GoogleMapsData.GoogleMapsUrl,
+		fmt.Sprintf("%.1f (%d reviews)", r.GoogleMapsData.Rating, r.GoogleMapsData.UserRatingCount),
+		r.RedditUrl,
+		fmt.Sprintf("%.6f", r.GoogleMapsData.Latitude),
+		fmt.Sprintf("%.6f", r.GoogleMapsData.Longitude),
+	}, nil
+}
+
+// detectSchema identifies the schema of a file from its header row.
+func detectSchema(header []string) (Schema, error) {
+	if len(header) > 0 && header[0] == structuredHeader[0] {
+		return SchemaStructured, nil
+	}
+	if len(header) > 0 && header[0] == legacyHeader[0] {
+		return SchemaLegacy, nil
+	}
+	return "", fmt.Errorf("unrecognized CSV header %q", header)
+}
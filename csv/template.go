@@ -0,0 +1,82 @@
+package csv
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+// Layout formats restaurants as CSV rows.
+type Layout interface {
+	Header() []string
+	Row(rank int, r maps.Restaurant) ([]string, error)
+}
+
+// Column is a user-defined CSV column. Template is a Go text/template
+// expression evaluated against a Row, e.g. "{{.GoogleMapsData.Name}} (#{{.Rank}})".
+type Column struct {
+	Header   string `json:"header"`
+	Template string `json:"template"`
+}
+
+// Row is the data available to column templates. Restaurant fields such as
+// .Name, .Upvotes, .Neighborhood, .Borough, .Dishes, .PostTitle, .Mentions and
+// .GoogleMapsData are promoted, so they can be referenced directly.
+type Row struct {
+	Rank int
+	maps.Restaurant
+}
+
+// templateFuncs are the helpers available to column templates in addition to
+// the text/template builtins.
+var templateFuncs = template.FuncMap{
+	"join":    strings.Join,
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"placeID": func(d maps.GoogleMapsData) string { return d.PlaceID() },
+}
+
+// TemplateLayout is a Layout built from user-defined columns.
+type TemplateLayout struct {
+	header    []string
+	templates []*template.Template
+}
+
+// NewTemplateLayout parses the templates of the given columns.
+func NewTemplateLayout(columns []Column) (*TemplateLayout, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("at least one CSV column is required")
+	}
+
+	layout := &TemplateLayout{}
+	for _, column := range columns {
+		tmpl, err := template.New(column.Header).Funcs(templateFuncs).Option("missingkey=error").Parse(column.Template)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing template for column %q: %v", column.Header, err)
+		}
+		layout.header = append(layout.header, column.Header)
+		layout.templates = append(layout.templates, tmpl)
+	}
+	return layout, nil
+}
+
+// Header returns the configured column headers.
+func (l *TemplateLayout) Header() []string {
+	return l.header
+}
+
+// Row evaluates each column template for a restaurant at the given 1-based rank.
+func (l *TemplateLayout) Row(rank int, r maps.Restaurant) ([]string, error) {
+	data := Row{Rank: rank, Restaurant: r}
+	row := make([]string, len(l.templates))
+	for i, tmpl := range l.templates {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("error rendering column %q: %v", l.header[i], err)
+		}
+		row[i] = b.String()
+	}
+	return row, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tonyjhuang/reddit-to-gmap/cache"
+	"github.com/tonyjhuang/reddit-to-gmap/csv"
+	"github.com/tonyjhuang/reddit-to-gmap/diff"
+	"github.com/tonyjhuang/reddit-to-gmap/job"
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+	"github.com/tonyjhuang/reddit-to-gmap/run"
+)
+
+var (
+	diffFormat string
+	diffOut    string
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old-run> <new-run>",
+	Short: "Report new, dropped and moved restaurants between two runs",
+	Long: `Compare two runs and report new entries, dropped entries, rank movements and
+rating changes. Each run is a CSV file written by this tool, a cache key of
+stored restaurant data (e.g. foodnyc_full_restaurants) or the ID of a completed
+run.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		oldRun, err := loadRun(args[0])
+		if err != nil {
+			return err
+		}
+		newRun, err := loadRun(args[1])
+		if err != nil {
+			return err
+		}
+
+		report := diff.Compare(runName(args[0]), oldRun, runName(args[1]), newRun)
+
+		var w io.Writer = os.Stdout
+		if diffOut != "" {
+			file, err := os.Create(diffOut)
+			if err != nil {
+				return fmt.Errorf("error creating diff output: %v", err)
+			}
+			defer file.Close()
+			w = file
+		}
+
+		switch diffFormat {
+		case "markdown":
+			return report.WriteMarkdown(w)
+		case "json":
+			return report.WriteJSON(w)
+		default:
+			return fmt.Errorf("unknown diff format %q (expected markdown or json)", diffFormat)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringVarP(&diffFormat, "format", "f", "markdown", "Output format (markdown, json)")
+	diffCmd.Flags().StringVar(&diffOut, "out", "", "File to write the report to (defaults to stdout)")
+}
+
+// loadRun loads a ranked run from a CSV file, from stored restaurant data in
+// the cache or from the results of a completed run.
+func loadRun(name string) ([]maps.Restaurant, error) {
+	if strings.EqualFold(filepath.Ext(name), ".csv") {
+		return csv.ReadFile(name)
+	}
+
+	if !cache.CacheExists(name) {
+		return loadRunResults(name)
+	}
+	cacheData, err := cache.ReadFromCache(name)
+	if err != nil {
+		return nil, err
+	}
+	jsonData, err := json.Marshal(cacheData.Data)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling cache data: %v", err)
+	}
+	var restaurants []maps.Restaurant
+	if err := json.Unmarshal(jsonData, &restaurants); err != nil {
+		return nil, fmt.Errorf("error unmarshaling cache data: %v", err)
+	}
+
+	// Stored runs are unranked; rank them the same way the CSV export does.
+	sort.SliceStable(restaurants, func(i, j int) bool {
+		return restaurants[i].Upvotes > restaurants[j].Upvotes
+	})
+	return restaurants, nil
+}
+
+// loadRunResults loads the ranked restaurants saved by the run with the
+// given ID.
+func loadRunResults(id string) ([]maps.Restaurant, error) {
+	state, err := run.Load(run.DefaultDir, id)
+	if err != nil {
+		return nil, fmt.Errorf("no CSV file, stored run or run ID named %q", id)
+	}
+	restaurants, err := job.Results(state)
+	if err != nil {
+		return nil, err
+	}
+	if restaurants == nil {
+		return nil, fmt.Errorf("run %s has no saved results (status %s)", id, state.Status)
+	}
+	return restaurants, nil
+}
+
+// runName returns a short label for a run argument.
+func runName(name string) string {
+	return strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+}
@@ -0,0 +1,203 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+// Entry is a single ranked restaurant from one side of a comparison.
+type Entry struct {
+	Rank            int     `json:"rank"`
+	Name            string  `json:"name"`
+	Upvotes         int     `json:"upvotes"`
+	Rating          float64 `json:"rating"`
+	UserRatingCount int     `json:"user_rating_count"`
+	GoogleMapsUrl   string  `json:"google_maps_url"`
+	RedditUrl       string  `json:"reddit_url"`
+}
+
+// Movement is a restaurant present in both runs at a different rank.
+type Movement struct {
+	Entry
+	PreviousRank int `json:"previous_rank"`
+}
+
+// RatingChange is a restaurant present in both runs whose Google Maps rating changed.
+type RatingChange struct {
+	Entry
+	PreviousRating          float64 `json:"previous_rating"`
+	PreviousUserRatingCount int     `json:"previous_user_rating_count"`
+}
+
+// Report describes what changed between an old and a new run.
+type Report struct {
+	Old           string         `json:"old"`
+	New           string         `json:"new"`
+	Added         []Entry        `json:"added"`
+	Dropped       []Entry        `json:"dropped"`
+	Moved         []Movement     `json:"moved"`
+	RatingChanges []RatingChange `json:"rating_changes"`
+	Unchanged     int            `json:"unchanged"`
+}
+
+// Key returns the identity used to match a restaurant across runs: its place
+// ID when known, otherwise its Google Maps URL, otherwise its name.
+func Key(r maps.Restaurant) string {
+	if id := r.GoogleMapsData.PlaceID(); id != "" {
+		return id
+	}
+	if r.GoogleMapsData.GoogleMapsUrl != "" {
+		return r.GoogleMapsData.GoogleMapsUrl
+	}
+	return strings.ToLower(r.GoogleMapsData.Name)
+}
+
+// index ranks restaurants by their position in the slice, keeping only the
+// best ranked occurrence of each restaurant.
+func index(restaurants []maps.Restaurant) ([]string, map[string]Entry) {
+	var keys []string
+	entries := make(map[string]Entry)
+	for i, r := range restaurants {
+		key := Key(r)
+		if _, found := entries[key]; found {
+			continue
+		}
+		keys = append(keys, key)
+		entries[key] = Entry{
+			Rank:            i + 1,
+			Name:            r.GoogleMapsData.Name,
+			Upvotes:         r.Upvotes,
+			Rating:          r.GoogleMapsData.Rating,
+			UserRatingCount: r.GoogleMapsData.UserRatingCount,
+			GoogleMapsUrl:   r.GoogleMapsData.GoogleMapsUrl,
+			RedditUrl:       r.RedditUrl,
+		}
+	}
+	return keys, entries
+}
+
+// Entries returns the entries of a ranked run in rank order, keeping only
+// the best ranked occurrence of each restaurant.
+func Entries(restaurants []maps.Restaurant) []Entry {
+	keys, entries := index(restaurants)
+	result := make([]Entry, len(keys))
+	for i, key := range keys {
+		result[i] = entries[key]
+	}
+	return result
+}
+
+// Compare reports the differences between two ranked runs. Both slices must
+// already be in rank order.
+func Compare(oldName string, oldRun []maps.Restaurant, newName string, newRun []maps.Restaurant) *Report {
+	report := &Report{Old: oldName, New: newName}
+
+	oldKeys, oldEntries := index(oldRun)
+	newKeys, newEntries := index(newRun)
+
+	for _, key := range newKeys {
+		entry := newEntries[key]
+		previous, found := oldEntries[key]
+		if !found {
+			report.Added = append(report.Added, entry)
+			continue
+		}
+
+		changed := false
+		if previous.Rank != entry.Rank {
+			report.Moved = append(report.Moved, Movement{Entry: entry, PreviousRank: previous.Rank})
+			changed = true
+		}
+		// Ratings are written with one decimal place, so compare at that precision
+		// to avoid reporting float noise between CSV and cached runs.
+		if math.Round(previous.Rating*10) != math.Round(entry.Rating*10) {
+			report.RatingChanges = append(report.RatingChanges, RatingChange{
+				Entry:                   entry,
+				PreviousRating:          previous.Rating,
+				PreviousUserRatingCount: previous.UserRatingCount,
+			})
+			changed = true
+		}
+		if !changed {
+			report.Unchanged++
+		}
+	}
+
+	for _, key := range oldKeys {
+		if _, found := newEntries[key]; !found {
+			report.Dropped = append(report.Dropped, oldEntries[key])
+		}
+	}
+
+	return report
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(r)
+}
+
+// WriteMarkdown writes the report as a Markdown changelog.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Changes from %s to %s\n\n", r.Old, r.New)
+	fmt.Fprintf(&b, "%d new, %d dropped, %d moved, %d rating changes, %d unchanged.\n",
+		len(r.Added), len(r.Dropped), len(r.Moved), len(r.RatingChanges), r.Unchanged)
+
+	if len(r.Added) > 0 {
+		b.WriteString("\n## New\n\n| Rank | Restaurant | Upvotes | Rating |\n| --- | --- | --- | --- |\n")
+		for _, e := range r.Added {
+			fmt.Fprintf(&b, "| %d | %s | %d | %.1f (%d reviews) |\n", e.Rank, link(e), e.Upvotes, e.Rating, e.UserRatingCount)
+		}
+	}
+
+	if len(r.Dropped) > 0 {
+		b.WriteString("\n## Dropped\n\n| Previous rank | Restaurant | Upvotes |\n| --- | --- | --- |\n")
+		for _, e := range r.Dropped {
+			fmt.Fprintf(&b, "| %d | %s | %d |\n", e.Rank, link(e), e.Upvotes)
+		}
+	}
+
+	if len(r.Moved) > 0 {
+		b.WriteString("\n## Rank changes\n\n| Restaurant | Rank | Change |\n| --- | --- | --- |\n")
+		for _, m := range r.Moved {
+			fmt.Fprintf(&b, "| %s | %d → %d | %s |\n", link(m.Entry), m.PreviousRank, m.Rank, arrow(m.PreviousRank-m.Rank))
+		}
+	}
+
+	if len(r.RatingChanges) > 0 {
+		b.WriteString("\n## Rating changes\n\n| Restaurant | Rating | Reviews |\n| --- | --- | --- |\n")
+		for _, c := range r.RatingChanges {
+			fmt.Fprintf(&b, "| %s | %.1f → %.1f | %d → %d |\n", link(c.Entry), c.PreviousRating, c.Rating, c.PreviousUserRatingCount, c.UserRatingCount)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// link renders an entry's name as a Markdown link to its Google Maps page.
+func link(e Entry) string {
+	name := strings.NewReplacer("|", `\|`, "[", `\[`, "]", `\]`).Replace(e.Name)
+	if e.GoogleMapsUrl == "" {
+		return name
+	}
+	return fmt.Sprintf("[%s](%s)", name, e.GoogleMapsUrl)
+}
+
+// arrow renders a rank delta, where positive means the restaurant climbed.
+func arrow(delta int) string {
+	if delta > 0 {
+		return fmt.Sprintf("▲ %d", delta)
+	}
+	return fmt.Sprintf("▼ %d", -delta)
+}
@@ -0,0 +1,166 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+// place returns a restaurant with a place ID, so it matches across runs by
+// that ID whatever its name.
+func place(id, name string, rating float64) maps.Restaurant {
+	return maps.Restaurant{
+		Name: name,
+		GoogleMapsData: maps.GoogleMapsData{
+			Name:          name,
+			Rating:        rating,
+			GoogleMapsUrl: "https://www.google.com/maps/search/?api=1&query=x&query_place_id=" + id,
+		},
+	}
+}
+
+func names(entries []Entry) []string {
+	result := make([]string, len(entries))
+	for i, e := range entries {
+		result[i] = e.Name
+	}
+	return result
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name string
+		r    maps.Restaurant
+		want string
+	}{
+		{"place ID", place("ChIJa", "Joe's", 0), "ChIJa"},
+		{
+			"Google Maps URL without a place ID",
+			maps.Restaurant{GoogleMapsData: maps.GoogleMapsData{Name: "Joe's", GoogleMapsUrl: "https://maps.google.com/?cid=1"}},
+			"https://maps.google.com/?cid=1",
+		},
+		{"name", maps.Restaurant{GoogleMapsData: maps.GoogleMapsData{Name: "Joe's Pizza"}}, "joe's pizza"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Key(tt.r); got != tt.want {
+				t.Errorf("Key() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntries(t *testing.T) {
+	// A restaurant listed twice keeps its best rank
+	entries := Entries([]maps.Restaurant{
+		place("a", "A", 4.5),
+		place("b", "B", 4.0),
+		place("a", "A again", 4.5),
+		place("c", "C", 3.9),
+	})
+	if got := names(entries); !slices.Equal(got, []string{"A", "B", "C"}) {
+		t.Errorf("Entries() = %v, want [A B C]", got)
+	}
+	if entries[2].Rank != 4 {
+		t.Errorf("C rank = %d, want 4", entries[2].Rank)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	oldRun := []maps.Restaurant{
+		place("a", "A", 4.5),
+		place("b", "B", 4.0),
+		place("c", "C", 4.2),
+		place("d", "D", 4.8),
+	}
+	newRun := []maps.Restaurant{
+		place("c", "C", 4.2),
+		place("a", "A", 4.5),
+		place("e", "E", 4.1),
+		place("d", "D renamed", 4.64),
+		place("b", "B", 4.02), // Rating noise below the displayed precision
+	}
+	report := Compare("old", oldRun, "new", newRun)
+
+	if got := names(report.Added); !slices.Equal(got, []string{"E"}) {
+		t.Errorf("Added = %v, want [E]", got)
+	}
+	if report.Added[0].Rank != 3 {
+		t.Errorf("E rank = %d, want 3", report.Added[0].Rank)
+	}
+	if len(report.Dropped) != 0 {
+		t.Errorf("Dropped = %v, want none", names(report.Dropped))
+	}
+
+	var moved []string
+	for _, m := range report.Moved {
+		moved = append(moved, m.Name+" "+arrow(m.PreviousRank-m.Rank))
+	}
+	if want := []string{"C ▲ 2", "A ▼ 1", "B ▼ 3"}; !slices.Equal(moved, want) {
+		t.Errorf("Moved = %q, want %q", moved, want)
+	}
+
+	if len(report.RatingChanges) != 1 || report.RatingChanges[0].Name != "D renamed" || report.RatingChanges[0].PreviousRating != 4.8 {
+		t.Errorf("RatingChanges = %+v, want D from 4.8", report.RatingChanges)
+	}
+	if report.Unchanged != 0 {
+		t.Errorf("Unchanged = %d, want 0", report.Unchanged)
+	}
+
+	// Compared the other way round, E is dropped
+	back := Compare("new", newRun, "old", oldRun)
+	if got := names(back.Dropped); !slices.Equal(got, []string{"E"}) {
+		t.Errorf("Dropped = %v, want [E]", got)
+	}
+	if len(back.Added) != 0 {
+		t.Errorf("Added = %v, want none", names(back.Added))
+	}
+}
+
+func TestCompareUnchanged(t *testing.T) {
+	run := []maps.Restaurant{place("a", "A", 4.5), place("b", "B", 4.0)}
+	report := Compare("old", run, "new", run)
+	if report.Unchanged != 2 || len(report.Added)+len(report.Dropped)+len(report.Moved)+len(report.RatingChanges) != 0 {
+		t.Errorf("report = %+v, want 2 unchanged", report)
+	}
+}
+
+func TestArrow(t *testing.T) {
+	tests := []struct {
+		delta int
+		want  string
+	}{
+		{3, "▲ 3"},
+		{1, "▲ 1"},
+		{-1, "▼ 1"},
+		{-12, "▼ 12"},
+	}
+	for _, tt := range tests {
+		if got := arrow(tt.delta); got != tt.want {
+			t.Errorf("arrow(%d) = %q, want %q", tt.delta, got, tt.want)
+		}
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	report := Compare("old", []maps.Restaurant{place("a", "A", 4.5)}, "new", []maps.Restaurant{
+		place("b", "Bar | Grill [NYC]", 4.0),
+		place("a", "A", 4.5),
+	})
+	var b strings.Builder
+	if err := report.WriteMarkdown(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# Changes from old to new",
+		"1 new, 0 dropped, 1 moved, 0 rating changes, 0 unchanged.",
+		`| 1 | [Bar \| Grill \[NYC\]](https://www.google.com/maps/search/?api=1&query=x&query_place_id=b) | 0 | 4.0 (0 reviews) |`,
+		"| 1 → 2 | ▼ 1 |",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("markdown = %s\nwant it to contain %s", b.String(), want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package dump reads Reddit submission archives: newline-delimited JSON, one
+// submission per line, usually zstd-compressed.
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/tonyjhuang/reddit-to-gmap/reddit"
+)
+
+// maxWindowSize is the zstd window size used by Reddit archive dumps, which
+// is larger than the decoder's default limit.
+const maxWindowSize = 1 << 31
+
+// Filter selects submissions from a dump. Zero fields match everything.
+type Filter struct {
+	Subreddit string
+	Since     time.Time // Inclusive
+	Until     time.Time // Exclusive
+}
+
+func (f Filter) match(subreddit string, created time.Time) bool {
+	if f.Subreddit != "" && !strings.EqualFold(f.Subreddit, subreddit) {
+		return false
+	}
+	if !f.Since.IsZero() && created.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !created.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Stats counts what was read from a dump.
+type Stats struct {
+	Lines     int `json:"lines"`
+	Matched   int `json:"matched"`
+	Malformed int `json:"malformed"`
+}
+
+// record is a submission line. Older dumps store created_utc as a string, so
+// it is decoded separately from the rest of the post.
+type record struct {
+	reddit.PostData
+	Subreddit  string          `json:"subreddit"`
+	CreatedUTC json.RawMessage `json:"created_utc"`
+}
+
+// ReadFile reads the dump at path. Files ending in .zst are decompressed;
+// anything else is read as plain NDJSON.
+func ReadFile(path string, filter Filter) ([]reddit.Post, Stats, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, Stats{}, fmt.Errorf("error opening dump: %v", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".zst") {
+		decoder, err := zstd.NewReader(file, zstd.WithDecoderMaxWindow(maxWindowSize))
+		if err != nil {
+			return nil, Stats{}, fmt.Errorf("error creating zstd decoder: %v", err)
+		}
+		defer decoder.Close()
+		r = decoder
+	}
+
+	return Read(r, filter)
+}
+
+// Read streams NDJSON submissions from r and returns those matching filter,
+// converted to posts with absolute permalinks. Malformed lines are counted
+// and skipped.
+func Read(r io.Reader, filter Filter) ([]reddit.Post, Stats, error) {
+	var posts []reddit.Post
+	var stats Stats
+
+	// Lines can be very long, so read them whole instead of using a Scanner
+	// with a fixed buffer.
+	reader := bufio.NewReaderSize(r, 1<<20)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			stats.Lines++
+			post, subreddit, ok := parseLine(line)
+			if !ok {
+				stats.Malformed++
+			} else if filter.match(subreddit, post.Data.Created()) {
+				stats.Matched++
+				posts = append(posts, post)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, stats, fmt.Errorf("error reading dump after %d lines: %v", stats.Lines, err)
+		}
+	}
+
+	return posts, stats, nil
+}
+
+// parseLine decodes one submission and returns it with its subreddit.
+func parseLine(line []byte) (reddit.Post, string, bool) {
+	var rec record
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return reddit.Post{}, "", false
+	}
+
+	created, err := strconv.ParseFloat(strings.Trim(string(rec.CreatedUTC), `"`), 64)
+	if err != nil {
+		return reddit.Post{}, "", false
+	}
+	rec.PostData.CreatedUTC = created
+
+	if strings.HasPrefix(rec.PostData.Permalink, "/") {
+		rec.PostData.Permalink = "https://www.reddit.com" + rec.PostData.Permalink
+	}
+	return reddit.Post{Data: rec.PostData}, rec.Subreddit, true
+}
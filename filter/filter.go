@@ -0,0 +1,184 @@
+package filter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/tonyjhuang/reddit-to-gmap/reddit"
+)
+
+// Config selects which posts are worth sending to the extraction stage. The
+// zero value keeps every post but removed and deleted ones, as the
+// command-line defaults do.
+type Config struct {
+	MinScore    int `json:"min_score,omitempty"`
+	MinComments int `json:"min_comments,omitempty"`
+	// MinUpvoteRatio is skipped for posts without a known ratio, such as
+	// posts cached before the ratio was recorded.
+	MinUpvoteRatio float64 `json:"min_upvote_ratio,omitempty"`
+	// FlairAllow keeps only posts with one of these flairs; FlairDeny drops
+	// posts with any of them. Both are case-insensitive.
+	FlairAllow []string `json:"flair_allow,omitempty"`
+	FlairDeny  []string `json:"flair_deny,omitempty"`
+	// TitleMatch keeps only posts whose title matches the regular expression;
+	// TitleExclude drops posts whose title matches it.
+	TitleMatch        string `json:"title_match,omitempty"`
+	TitleExclude      string `json:"title_exclude,omitempty"`
+	MinSelftextLength int    `json:"min_selftext_length,omitempty"`
+	// ExcludeRemoved drops removed and deleted posts. Nil means true, so
+	// that a job can keep them by setting it to false.
+	ExcludeRemoved *bool `json:"exclude_removed,omitempty"`
+	ExcludeNSFW    bool  `json:"exclude_nsfw,omitempty"`
+}
+
+// Key identifies the posts a config keeps, for the cache keys of the stages
+// after the filter. It is empty for the command-line defaults, which only
+// drop removed posts, so their cache keys stay the same as before filters
+// existed; any other config gets a short hash of its settings.
+func (c Config) Key() string {
+	if c.excludeRemoved() {
+		c.ExcludeRemoved = nil
+	}
+	// Config is plain data, so marshaling can't fail
+	data, _ := json.Marshal(c)
+	if string(data) == "{}" {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:4])
+}
+
+// excludeRemoved reports whether removed and deleted posts are dropped.
+func (c Config) excludeRemoved() bool {
+	return c.ExcludeRemoved == nil || *c.ExcludeRemoved
+}
+
+// Drop records a post that was filtered out and why.
+type Drop struct {
+	Title     string `json:"title"`
+	Permalink string `json:"permalink"`
+	Reason    string `json:"reason"`
+}
+
+// Result is the outcome of filtering a set of posts.
+type Result struct {
+	Kept    []reddit.Post `json:"-"`
+	Dropped []Drop        `json:"dropped"`
+}
+
+// Reasons counts dropped posts by reason.
+func (r *Result) Reasons() map[string]int {
+	reasons := make(map[string]int)
+	for _, drop := range r.Dropped {
+		reasons[drop.Reason]++
+	}
+	return reasons
+}
+
+// Summary describes how many posts were dropped, most common reason first.
+func (r *Result) Summary() string {
+	total := len(r.Kept) + len(r.Dropped)
+	if len(r.Dropped) == 0 {
+		return fmt.Sprintf("kept all %d posts", total)
+	}
+
+	reasons := r.Reasons()
+	names := make([]string, 0, len(reasons))
+	for reason := range reasons {
+		names = append(names, reason)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if reasons[names[i]] != reasons[names[j]] {
+			return reasons[names[i]] > reasons[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	parts := make([]string, len(names))
+	for i, reason := range names {
+		parts[i] = fmt.Sprintf("%d %s", reasons[reason], reason)
+	}
+	return fmt.Sprintf("kept %d of %d posts, dropped %s", len(r.Kept), total, strings.Join(parts, ", "))
+}
+
+// Filter applies a Config to posts.
+type Filter struct {
+	config       Config
+	titleMatch   *regexp.Regexp
+	titleExclude *regexp.Regexp
+}
+
+// New compiles a filter from its configuration.
+func New(config Config) (*Filter, error) {
+	f := &Filter{config: config}
+
+	var err error
+	if config.TitleMatch != "" {
+		if f.titleMatch, err = regexp.Compile(config.TitleMatch); err != nil {
+			return nil, fmt.Errorf("invalid title match pattern: %v", err)
+		}
+	}
+	if config.TitleExclude != "" {
+		if f.titleExclude, err = regexp.Compile(config.TitleExclude); err != nil {
+			return nil, fmt.Errorf("invalid title exclude pattern: %v", err)
+		}
+	}
+	return f, nil
+}
+
+// Apply splits posts into those kept and those dropped, preserving order.
+func (f *Filter) Apply(posts []reddit.Post) *Result {
+	result := &Result{Kept: make([]reddit.Post, 0, len(posts))}
+	for _, post := range posts {
+		if reason := f.reject(post.Data); reason != "" {
+			result.Dropped = append(result.Dropped, Drop{
+				Title:     post.Data.Title,
+				Permalink: post.Data.Permalink,
+				Reason:    reason,
+			})
+			continue
+		}
+		result.Kept = append(result.Kept, post)
+	}
+	return result
+}
+
+// reject returns why a post should be dropped, or "" to keep it.
+func (f *Filter) reject(post reddit.PostData) string {
+	c := f.config
+	switch {
+	case c.excludeRemoved() && post.Removed():
+		return "removed"
+	case c.ExcludeNSFW && post.Over18:
+		return "nsfw"
+	case c.MinScore != 0 && post.Score < c.MinScore:
+		return "low score"
+	case post.NumComments < c.MinComments:
+		return "few comments"
+	case c.MinUpvoteRatio > 0 && post.UpvoteRatio > 0 && post.UpvoteRatio < c.MinUpvoteRatio:
+		return "low upvote ratio"
+	case len(c.FlairAllow) > 0 && !containsFold(c.FlairAllow, post.Flair):
+		return "flair not allowed"
+	case containsFold(c.FlairDeny, post.Flair):
+		return "flair denied"
+	case f.titleMatch != nil && !f.titleMatch.MatchString(post.Title):
+		return "title not matched"
+	case f.titleExclude != nil && f.titleExclude.MatchString(post.Title):
+		return "title excluded"
+	case len(strings.TrimSpace(post.Selftext)) < c.MinSelftextLength:
+		return "short selftext"
+	}
+	return ""
+}
+
+func containsFold(values []string, s string) bool {
+	return slices.ContainsFunc(values, func(v string) bool {
+		return strings.EqualFold(v, s)
+	})
+}
@@ -0,0 +1,172 @@
+package filter_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/tonyjhuang/reddit-to-gmap/filter"
+	"github.com/tonyjhuang/reddit-to-gmap/reddit"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// reason filters a single post and returns why it was dropped, or "" if it
+// was kept.
+func reason(t *testing.T, config filter.Config, post reddit.PostData) string {
+	t.Helper()
+	f, err := filter.New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := f.Apply([]reddit.Post{{Data: post}})
+	if len(result.Dropped) == 0 {
+		return ""
+	}
+	return result.Dropped[0].Reason
+}
+
+func TestApply(t *testing.T) {
+	review := reddit.PostData{
+		Title:       "Review: Joe's Pizza",
+		Selftext:    "The best slice in the Village.",
+		Author:      "eater",
+		Score:       120,
+		NumComments: 14,
+		UpvoteRatio: 0.95,
+		Flair:       "Review",
+	}
+	with := func(change func(*reddit.PostData)) reddit.PostData {
+		post := review
+		change(&post)
+		return post
+	}
+	removed := with(func(p *reddit.PostData) { p.Selftext = "[removed]" })
+	deleted := with(func(p *reddit.PostData) { p.Author = "[deleted]" })
+
+	tests := []struct {
+		name   string
+		config filter.Config
+		post   reddit.PostData
+		want   string
+	}{
+		{"defaults keep a review", filter.Config{}, review, ""},
+
+		{"below min score", filter.Config{MinScore: 121}, review, "low score"},
+		{"at min score", filter.Config{MinScore: 120}, review, ""},
+		{"below min comments", filter.Config{MinComments: 15}, review, "few comments"},
+		{"at min comments", filter.Config{MinComments: 14}, review, ""},
+		{"below min upvote ratio", filter.Config{MinUpvoteRatio: 0.96}, review, "low upvote ratio"},
+		{
+			"unknown upvote ratio",
+			filter.Config{MinUpvoteRatio: 0.96},
+			with(func(p *reddit.PostData) { p.UpvoteRatio = 0 }),
+			"",
+		},
+
+		{"flair allowed", filter.Config{FlairAllow: []string{"review", "Recommendation"}}, review, ""},
+		{"flair not allowed", filter.Config{FlairAllow: []string{"Recommendation"}}, review, "flair not allowed"},
+		{
+			"no flair with an allow list",
+			filter.Config{FlairAllow: []string{"Review"}},
+			with(func(p *reddit.PostData) { p.Flair = "" }),
+			"flair not allowed",
+		},
+		{"flair denied", filter.Config{FlairDeny: []string{"REVIEW"}}, review, "flair denied"},
+		{"other flair denied", filter.Config{FlairDeny: []string{"Question"}}, review, ""},
+
+		{"title matched", filter.Config{TitleMatch: `(?i)^review`}, review, ""},
+		{"title not matched", filter.Config{TitleMatch: `(?i)^where`}, review, "title not matched"},
+		{"title excluded", filter.Config{TitleExclude: `Pizza`}, review, "title excluded"},
+		{"short selftext", filter.Config{MinSelftextLength: 100}, review, "short selftext"},
+		{"nsfw", filter.Config{ExcludeNSFW: true}, with(func(p *reddit.PostData) { p.Over18 = true }), "nsfw"},
+
+		{"removed by default", filter.Config{}, removed, "removed"},
+		{"deleted by default", filter.Config{}, deleted, "removed"},
+		{
+			"removed by a moderator",
+			filter.Config{},
+			with(func(p *reddit.PostData) { p.RemovedByCategory = "moderator" }),
+			"removed",
+		},
+		{"removed, excluded", filter.Config{ExcludeRemoved: boolPtr(true)}, removed, "removed"},
+		{"removed, kept", filter.Config{ExcludeRemoved: boolPtr(false)}, removed, ""},
+		{"deleted, kept", filter.Config{ExcludeRemoved: boolPtr(false)}, deleted, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reason(t, tt.config, tt.post); got != tt.want {
+				t.Errorf("reason = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyKeepsOrder(t *testing.T) {
+	f, err := filter.New(filter.Config{MinScore: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts := []reddit.Post{
+		{Data: reddit.PostData{Title: "a", Score: 50}},
+		{Data: reddit.PostData{Title: "b", Score: 5}},
+		{Data: reddit.PostData{Title: "c", Score: 10}},
+		{Data: reddit.PostData{Title: "d", Score: 1}},
+	}
+	result := f.Apply(posts)
+	if len(result.Kept) != 2 || result.Kept[0].Data.Title != "a" || result.Kept[1].Data.Title != "c" {
+		t.Errorf("kept %+v, want a and c", result.Kept)
+	}
+	if got := result.Summary(); got != "kept 2 of 4 posts, dropped 2 low score" {
+		t.Errorf("Summary() = %q", got)
+	}
+}
+
+func TestNewRejectsInvalidPattern(t *testing.T) {
+	if _, err := filter.New(filter.Config{TitleMatch: "("}); err == nil {
+		t.Error("New() accepted an invalid title match pattern")
+	}
+	if _, err := filter.New(filter.Config{TitleExclude: "["}); err == nil {
+		t.Error("New() accepted an invalid title exclude pattern")
+	}
+}
+
+func TestKey(t *testing.T) {
+	// The defaults keep the cache keys from before filters existed
+	for _, config := range []filter.Config{{}, {ExcludeRemoved: boolPtr(true)}} {
+		if key := config.Key(); key != "" {
+			t.Errorf("Key() of %+v = %q, want \"\"", config, key)
+		}
+	}
+
+	config := filter.Config{MinScore: 20, FlairDeny: []string{"Meta"}, ExcludeNSFW: true}
+	key := config.Key()
+	if len(key) != 8 {
+		t.Fatalf("Key() = %q, want 8 hex digits", key)
+	}
+
+	// The same settings give the same key however they were written
+	var fromJSON filter.Config
+	if err := json.Unmarshal([]byte(`{"exclude_nsfw": true, "flair_deny": ["Meta"], "min_score": 20}`), &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if got := fromJSON.Key(); got != key {
+		t.Errorf("Key() of the same config from JSON = %q, want %q", got, key)
+	}
+	if got := (filter.Config{ExcludeNSFW: true, FlairDeny: []string{"Meta"}, MinScore: 20, ExcludeRemoved: boolPtr(true)}).Key(); got != key {
+		t.Errorf("Key() with ExcludeRemoved set to its default = %q, want %q", got, key)
+	}
+
+	// Any change gives another key
+	for _, other := range []filter.Config{
+		{MinScore: 21, FlairDeny: []string{"Meta"}, ExcludeNSFW: true},
+		{MinScore: 20, FlairAllow: []string{"Meta"}, ExcludeNSFW: true},
+		{MinScore: 20, FlairDeny: []string{"Meta"}},
+		{MinScore: 20, FlairDeny: []string{"Meta"}, ExcludeNSFW: true, ExcludeRemoved: boolPtr(false)},
+	} {
+		if other.Key() == key {
+			t.Errorf("Key() of %+v = %q, the same as %+v", other, key, config)
+		}
+	}
+}
@@ -4,23 +4,51 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/tonyjhuang/reddit-to-gmap/reddit"
 	"google.golang.org/genai"
 )
 
 type Restaurant struct {
-	Name          string `json:"name"`
-	Upvotes       int    `json:"upvotes"`
-	RedditUrl     string `json:"reddit_url"`
-	Neighborhood  string `json:"neighborhood,omitempty"`
-	GoogleMapsUrl string `json:"google_maps_url,omitempty"`
+	Name          string   `json:"name"`
+	Upvotes       int      `json:"upvotes"`
+	RedditUrl     string   `json:"reddit_url"`
+	Neighborhood  string   `json:"neighborhood,omitempty"`
+	Dishes        []string `json:"dishes,omitempty"`
+	GoogleMapsUrl string   `json:"google_maps_url,omitempty"`
+	// The remaining fields are filled in after extraction, not by the model.
+	PostID    string    `json:"post_id,omitempty"`
+	PostTitle string    `json:"post_title,omitempty"`
+	PostFlair string    `json:"post_flair,omitempty"`
+	PostedAt  time.Time `json:"posted_at,omitzero"`
+	PhotoURLs []string  `json:"photo_urls,omitempty"`
+	Mentions  int       `json:"mentions,omitempty"`
+}
+
+// promptPost is the part of a Reddit post sent to the model. Media and
+// moderation metadata are left out to keep the prompt small.
+type promptPost struct {
+	Title     string `json:"title"`
+	Permalink string `json:"permalink"`
+	Selftext  string `json:"selftext"`
+	Score     int    `json:"score"`
+	Flair     string `json:"flair,omitempty"`
 }
 
 type Client struct {
 	client *genai.Client
 	model  string
 	config *genai.GenerateContentConfig
+	usage  Usage
+}
+
+// Usage counts the requests a Client has made and the tokens they used.
+// Output tokens include the model's thinking tokens.
+type Usage struct {
+	Requests     int
+	InputTokens  int
+	OutputTokens int
 }
 
 func NewClient(ctx context.Context, apiKey string) (*Client, error) {
@@ -53,6 +81,10 @@ func NewClient(ctx context.Context, apiKey string) (*Client, error) {
 							"upvotes":      {Type: genai.TypeInteger},
 							"reddit_url":   {Type: genai.TypeString},
 							"neighborhood": {Type: genai.TypeString},
+							"dishes": {
+								Type:  genai.TypeArray,
+								Items: &genai.Schema{Type: genai.TypeString},
+							},
 							"google_maps_url": {
 								Type: genai.TypeString,
 							},
@@ -74,11 +106,26 @@ func (c *Client) Close() {
 	// google.golang.org/genai's client does not expose a Close method.
 }
 
+// Usage returns the requests made and tokens used so far.
+func (c *Client) Usage() Usage {
+	return c.usage
+}
+
 // ToRestaurantData processes Reddit posts and returns a slice of restaurants.
 // Each restaurant corresponds to a Reddit post that was identified as a restaurant review.
 func (c *Client) ToRestaurantData(ctx context.Context, posts []reddit.Post) ([]Restaurant, error) {
 	// Convert posts to JSON for the prompt
-	postsJSON, err := json.Marshal(posts)
+	promptPosts := make([]promptPost, len(posts))
+	for i, post := range posts {
+		promptPosts[i] = promptPost{
+			Title:     post.Data.Title,
+			Permalink: post.Data.Permalink,
+			Selftext:  post.Data.Selftext,
+			Score:     post.Data.Score,
+			Flair:     post.Data.Flair,
+		}
+	}
+	postsJSON, err := json.Marshal(promptPosts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal posts: %v", err)
 	}
@@ -96,15 +143,22 @@ Exclusion of Lists/Aggregations: The selftext must not explicitly list or compar
 
 Keywords (Optional, but helpful): The title or selftext may contain keywords like "review," "recommendation," "ate at," or similar phrases that indicate a review.  However, the presence of these keywords alone is not sufficient; the other conditions must also be met.
 
+Dishes: List the names of the specific dishes or drinks the post describes, if any.
+
 Skip any input Reddit posts that do not meet all of the above criteria. If a post's restaurant association or focus is unclear, or if it appears to be an aggregation or list, skip it.
 
 Input posts:
 %s`, string(postsJSON))
 
+	c.usage.Requests++
 	resp, err := c.client.Models.GenerateContent(ctx, c.model, genai.Text(prompt), c.config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate content: %v", err)
 	}
+	if usage := resp.UsageMetadata; usage != nil {
+		c.usage.InputTokens += int(usage.PromptTokenCount)
+		c.usage.OutputTokens += int(usage.CandidatesTokenCount + usage.ThoughtsTokenCount)
+	}
 
 	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
 		return nil, fmt.Errorf("no response generated")
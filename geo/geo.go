@@ -0,0 +1,219 @@
+// Package geo assigns restaurants to neighborhoods and boroughs by finding
+// the boundary polygon their coordinates fall in.
+//
+// Boundaries are read from GeoJSON files, typically one per city, whose
+// features are Polygons or MultiPolygons. A feature's neighborhood and
+// borough are read from the first of NeighborhoodProperties and
+// BoroughProperties it has, which covers common open data sets such as New
+// York City's Neighborhood Tabulation Areas (ntaname, boroname).
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+// NeighborhoodProperties and BoroughProperties are the feature properties
+// names are read from, in order of preference. Keys match case-insensitively.
+var (
+	NeighborhoodProperties = []string{"neighborhood", "ntaname", "nta_name", "neighbourhood", "name"}
+	BoroughProperties      = []string{"borough", "boroname", "boro_name", "district"}
+)
+
+// Area is a named neighborhood. Borough is empty if the boundary file does
+// not have one.
+type Area struct {
+	Neighborhood string
+	Borough      string
+}
+
+// Index finds the area a point is in.
+type Index struct {
+	areas []area
+}
+
+type area struct {
+	Area
+	bounds   bounds
+	polygons []polygon
+}
+
+// polygon is an outer ring followed by any holes. Points are [lng, lat], as
+// in GeoJSON.
+type polygon [][][2]float64
+
+type bounds struct {
+	minLng, minLat, maxLng, maxLat float64
+}
+
+func (b bounds) contains(lng, lat float64) bool {
+	return lng >= b.minLng && lng <= b.maxLng && lat >= b.minLat && lat <= b.maxLat
+}
+
+type featureCollection struct {
+	Features []struct {
+		Geometry struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+		Properties map[string]any `json:"properties"`
+	} `json:"features"`
+}
+
+// Load reads the boundaries in the given GeoJSON files into an index.
+func Load(paths ...string) (*Index, error) {
+	index := &Index{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading boundaries: %v", err)
+		}
+		if err := index.add(data); err != nil {
+			return nil, fmt.Errorf("error parsing boundaries %s: %v", path, err)
+		}
+	}
+	return index, nil
+}
+
+// add adds the features of a GeoJSON feature collection.
+func (x *Index) add(data []byte) error {
+	var collection featureCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return err
+	}
+
+	for i, feature := range collection.Features {
+		var polygons []polygon
+		switch feature.Geometry.Type {
+		case "Polygon":
+			var p polygon
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &p); err != nil {
+				return fmt.Errorf("feature %d: %v", i, err)
+			}
+			polygons = []polygon{p}
+		case "MultiPolygon":
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &polygons); err != nil {
+				return fmt.Errorf("feature %d: %v", i, err)
+			}
+		default:
+			// Points and lines can't contain anything
+			continue
+		}
+
+		a := area{
+			Area: Area{
+				Neighborhood: property(feature.Properties, NeighborhoodProperties),
+				Borough:      property(feature.Properties, BoroughProperties),
+			},
+			bounds:   bounds{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)},
+			polygons: polygons,
+		}
+		if a.Neighborhood == "" {
+			return fmt.Errorf("feature %d has none of the properties %s", i, strings.Join(NeighborhoodProperties, ", "))
+		}
+		for _, p := range polygons {
+			if len(p) == 0 {
+				continue
+			}
+			for _, point := range p[0] {
+				a.bounds.minLng = min(a.bounds.minLng, point[0])
+				a.bounds.minLat = min(a.bounds.minLat, point[1])
+				a.bounds.maxLng = max(a.bounds.maxLng, point[0])
+				a.bounds.maxLat = max(a.bounds.maxLat, point[1])
+			}
+		}
+		x.areas = append(x.areas, a)
+	}
+	return nil
+}
+
+// property returns the first of the named properties that is set.
+func property(properties map[string]any, names []string) string {
+	for _, name := range names {
+		for key, value := range properties {
+			if !strings.EqualFold(key, name) {
+				continue
+			}
+			if s, ok := value.(string); ok && strings.TrimSpace(s) != "" {
+				return strings.TrimSpace(s)
+			}
+		}
+	}
+	return ""
+}
+
+// Len returns the number of areas in the index.
+func (x *Index) Len() int {
+	return len(x.areas)
+}
+
+// Locate returns the area containing a point, or false if none does. If
+// areas overlap, the one loaded first wins.
+func (x *Index) Locate(lat, lng float64) (Area, bool) {
+	for _, a := range x.areas {
+		if !a.bounds.contains(lng, lat) {
+			continue
+		}
+		for _, p := range a.polygons {
+			if p.contains(lng, lat) {
+				return a.Area, true
+			}
+		}
+	}
+	return Area{}, false
+}
+
+// Assign sets the neighborhood and borough of each restaurant found in an
+// area, replacing the neighborhood guessed from its Reddit post, and returns
+// how many were found. Restaurants without coordinates are left as they are.
+func (x *Index) Assign(restaurants []maps.Restaurant) int {
+	located := 0
+	for i := range restaurants {
+		data := restaurants[i].GoogleMapsData
+		if data.Latitude == 0 && data.Longitude == 0 {
+			continue
+		}
+		a, ok := x.Locate(data.Latitude, data.Longitude)
+		if !ok {
+			continue
+		}
+		restaurants[i].Neighborhood = a.Neighborhood
+		restaurants[i].Borough = a.Borough
+		located++
+	}
+	return located
+}
+
+// contains reports whether a point is inside the polygon's outer ring and
+// outside its holes.
+func (p polygon) contains(lng, lat float64) bool {
+	if len(p) == 0 || !inRing(p[0], lng, lat) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if inRing(hole, lng, lat) {
+			return false
+		}
+	}
+	return true
+}
+
+// inRing casts a ray from the point and counts the edges it crosses; an odd
+// count means the point is inside. Neighborhoods are small enough to treat
+// coordinates as planar.
+func inRing(ring [][2]float64, lng, lat float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > lat) != (b[1] > lat) &&
+			lng < (b[0]-a[0])*(lat-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
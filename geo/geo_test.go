@@ -0,0 +1,174 @@
+package geo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+// square returns a closed ring around (lng, lat) with sides of 2*r degrees.
+func square(lng, lat, r float64) [][2]float64 {
+	return [][2]float64{
+		{lng - r, lat - r}, {lng + r, lat - r}, {lng + r, lat + r}, {lng - r, lat + r}, {lng - r, lat - r},
+	}
+}
+
+func TestInRing(t *testing.T) {
+	// A triangle whose bounding box is (0,0)-(2,2)
+	triangle := [][2]float64{{0, 0}, {2, 0}, {0, 2}, {0, 0}}
+
+	tests := []struct {
+		name     string
+		ring     [][2]float64
+		lng, lat float64
+		want     bool
+	}{
+		{"inside square", square(0, 0, 1), 0.5, 0.5, true},
+		{"outside square", square(0, 0, 1), 1.5, 0.5, false},
+		{"inside triangle", triangle, 0.5, 0.5, true},
+		{"in bounding box but outside triangle", triangle, 1.8, 1.8, false},
+		{"empty ring", nil, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inRing(tt.ring, tt.lng, tt.lat); got != tt.want {
+				t.Errorf("inRing(%v, %v) = %v, want %v", tt.lng, tt.lat, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolygonContains(t *testing.T) {
+	// A square with a square hole in the middle
+	donut := polygon{square(0, 0, 2), square(0, 0, 1)}
+
+	tests := []struct {
+		name     string
+		polygon  polygon
+		lng, lat float64
+		want     bool
+	}{
+		{"in ring, outside hole", donut, 1.5, 1.5, true},
+		{"in hole", donut, 0, 0, false},
+		{"outside", donut, 3, 0, false},
+		{"no rings", polygon{}, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.polygon.contains(tt.lng, tt.lat); got != tt.want {
+				t.Errorf("contains(%v, %v) = %v, want %v", tt.lng, tt.lat, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProperty(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties map[string]any
+		want       string
+	}{
+		{"first name", map[string]any{"neighborhood": "Astoria", "name": "Other"}, "Astoria"},
+		{"falls back", map[string]any{"NTAName": " Astoria ", "name": "Other"}, "Astoria"},
+		{"skips blank values", map[string]any{"neighborhood": " ", "name": "Astoria"}, "Astoria"},
+		{"skips non-strings", map[string]any{"neighborhood": 7, "name": "Astoria"}, "Astoria"},
+		{"none", map[string]any{"boroname": "Queens"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := property(tt.properties, NeighborhoodProperties); got != tt.want {
+				t.Errorf("property() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+const boundaries = `{
+  "type": "FeatureCollection",
+  "features": [
+    {
+      "type": "Feature",
+      "properties": {"ntaname": "Islands", "boroname": "Queens"},
+      "geometry": {
+        "type": "MultiPolygon",
+        "coordinates": [
+          [[[0, 0], [1, 0], [1, 1], [0, 1], [0, 0]]],
+          [[[5, 5], [6, 5], [6, 6], [5, 6], [5, 5]]]
+        ]
+      }
+    },
+    {
+      "type": "Feature",
+      "properties": {"name": "Park"},
+      "geometry": {"type": "Polygon", "coordinates": [[[10, 10], [11, 10], [11, 11], [10, 11], [10, 10]]]}
+    },
+    {
+      "type": "Feature",
+      "properties": {"name": "Marker"},
+      "geometry": {"type": "Point", "coordinates": [20, 20]}
+    }
+  ]
+}`
+
+func TestLocate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boundaries.geojson")
+	if err := os.WriteFile(path, []byte(boundaries), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	index, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.Len() != 2 {
+		t.Errorf("Len() = %d, want 2 (points are skipped)", index.Len())
+	}
+
+	tests := []struct {
+		name     string
+		lat, lng float64
+		want     Area
+		ok       bool
+	}{
+		{"first polygon of multipolygon", 0.5, 0.5, Area{"Islands", "Queens"}, true},
+		{"second polygon of multipolygon", 5.5, 5.5, Area{"Islands", "Queens"}, true},
+		{"between the polygons, in the bounding box", 3, 3, Area{}, false},
+		{"polygon without borough", 10.5, 10.5, Area{Neighborhood: "Park"}, true},
+		{"nowhere", 20, 20, Area{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := index.Locate(tt.lat, tt.lng)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Locate(%v, %v) = %v, %v, want %v, %v", tt.lat, tt.lng, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+
+	restaurants := []maps.Restaurant{
+		{Neighborhood: "Guess", GoogleMapsData: maps.GoogleMapsData{Latitude: 0.5, Longitude: 0.5}},
+		{Neighborhood: "Guess", GoogleMapsData: maps.GoogleMapsData{Latitude: 3, Longitude: 3}},
+		{Neighborhood: "No coordinates"},
+	}
+	if got := index.Assign(restaurants); got != 1 {
+		t.Errorf("Assign() = %d, want 1", got)
+	}
+	if restaurants[0].Neighborhood != "Islands" || restaurants[0].Borough != "Queens" {
+		t.Errorf("located restaurant = %q, %q", restaurants[0].Neighborhood, restaurants[0].Borough)
+	}
+	if restaurants[1].Neighborhood != "Guess" || restaurants[2].Neighborhood != "No coordinates" {
+		t.Errorf("unlocated restaurants changed: %q, %q", restaurants[1].Neighborhood, restaurants[2].Neighborhood)
+	}
+}
+
+func TestLoadRequiresNeighborhood(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boundaries.geojson")
+	data := `{"features": [{"properties": {"boroname": "Queens"}, "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}}]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load() succeeded for a feature without a neighborhood")
+	}
+}
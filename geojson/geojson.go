@@ -0,0 +1,75 @@
+// Package geojson writes restaurants as a GeoJSON FeatureCollection of
+// points (RFC 7946), for web maps and GIS tools.
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+// FeatureCollection is a GeoJSON feature collection.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON feature with a point geometry.
+type Feature struct {
+	Type       string         `json:"type"`
+	Geometry   Point          `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// Point is a GeoJSON point. Coordinates are longitude first.
+type Point struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// NewPoint returns the point at the given latitude and longitude.
+func NewPoint(lat, lng float64) Point {
+	return Point{Type: "Point", Coordinates: [2]float64{lng, lat}}
+}
+
+// Restaurants returns a feature per restaurant, in rank order. Restaurants
+// without coordinates are left out.
+func Restaurants(restaurants []maps.Restaurant) *FeatureCollection {
+	collection := &FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	for i, r := range restaurants {
+		data := r.GoogleMapsData
+		if data.Latitude == 0 && data.Longitude == 0 {
+			continue
+		}
+		collection.Features = append(collection.Features, Feature{
+			Type:     "Feature",
+			Geometry: NewPoint(data.Latitude, data.Longitude),
+			Properties: map[string]any{
+				"rank":              i + 1,
+				"name":              data.Name,
+				"type":              data.Type,
+				"rating":            data.Rating,
+				"user_rating_count": data.UserRatingCount,
+				"upvotes":           r.Upvotes,
+				"google_maps_url":   data.GoogleMapsUrl,
+				"reddit_url":        r.RedditUrl,
+				"neighborhood":      r.Neighborhood,
+				"borough":           r.Borough,
+			},
+		})
+	}
+	return collection
+}
+
+// Write writes the collection as indented JSON.
+func (c *FeatureCollection) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c); err != nil {
+		return fmt.Errorf("error writing GeoJSON: %v", err)
+	}
+	return nil
+}
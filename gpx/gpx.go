@@ -0,0 +1,144 @@
+// Package gpx writes restaurants as GPX 1.1 waypoints, which offline map apps
+// such as Organic Maps, OsmAnd and most GPS devices can import.
+package gpx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+// File is a GPX file of waypoints.
+type File struct {
+	Name      string
+	Waypoints []Waypoint
+}
+
+// Waypoint is a named point. Description is plain text.
+type Waypoint struct {
+	Name        string
+	Description string
+	// Type is a classification of the waypoint, e.g. the kind of restaurant.
+	Type      string
+	Latitude  float64
+	Longitude float64
+	Links     []Link
+}
+
+// Link is a link to more about a waypoint.
+type Link struct {
+	Href string
+	Text string
+}
+
+// Restaurants returns a waypoint per restaurant, in rank order. Restaurants
+// without coordinates are left out.
+func Restaurants(name string, restaurants []maps.Restaurant) *File {
+	file := &File{Name: name}
+	for i, r := range restaurants {
+		if waypoint, ok := RestaurantWaypoint(i+1, r); ok {
+			file.Waypoints = append(file.Waypoints, waypoint)
+		}
+	}
+	return file
+}
+
+// RestaurantWaypoint returns the waypoint of a ranked restaurant, or false if
+// it has no coordinates. The description has the rank, rating, upvotes and
+// links, as not every app shows a waypoint's links.
+func RestaurantWaypoint(rank int, r maps.Restaurant) (Waypoint, bool) {
+	data := r.GoogleMapsData
+	if data.Latitude == 0 && data.Longitude == 0 {
+		return Waypoint{}, false
+	}
+
+	var description strings.Builder
+	fmt.Fprintf(&description, "#%d", rank)
+	if data.Type != "" {
+		fmt.Fprintf(&description, " · %s", data.Type)
+	}
+	fmt.Fprintf(&description, "\nRating: %.1f (%d reviews)\nUpvotes: %d", data.Rating, data.UserRatingCount, r.Upvotes)
+
+	name := data.Name
+	if name == "" {
+		name = r.Name
+	}
+
+	waypoint := Waypoint{
+		Name:      name,
+		Type:      data.Type,
+		Latitude:  data.Latitude,
+		Longitude: data.Longitude,
+	}
+	if r.RedditUrl != "" {
+		fmt.Fprintf(&description, "\nReddit: %s", r.RedditUrl)
+		waypoint.Links = append(waypoint.Links, Link{Href: r.RedditUrl, Text: "Reddit post"})
+	}
+	if data.GoogleMapsUrl != "" {
+		fmt.Fprintf(&description, "\nGoogle Maps: %s", data.GoogleMapsUrl)
+		waypoint.Links = append(waypoint.Links, Link{Href: data.GoogleMapsUrl, Text: "Google Maps"})
+	}
+	waypoint.Description = description.String()
+	return waypoint, true
+}
+
+type gpxFile struct {
+	XMLName   xml.Name      `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Metadata  gpxMetadata   `xml:"metadata"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+}
+
+type gpxMetadata struct {
+	Name string `xml:"name"`
+}
+
+// gpxWaypoint lists its elements in the order the GPX schema requires.
+type gpxWaypoint struct {
+	Latitude    string    `xml:"lat,attr"`
+	Longitude   string    `xml:"lon,attr"`
+	Name        string    `xml:"name"`
+	Description string    `xml:"desc,omitempty"`
+	Links       []gpxLink `xml:"link"`
+	Type        string    `xml:"type,omitempty"`
+}
+
+type gpxLink struct {
+	Href string `xml:"href,attr"`
+	Text string `xml:"text,omitempty"`
+}
+
+// Write writes the file as GPX.
+func (f *File) Write(w io.Writer) error {
+	file := gpxFile{Version: "1.1", Creator: "reddit-to-gmap", Metadata: gpxMetadata{Name: f.Name}}
+	for _, wp := range f.Waypoints {
+		waypoint := gpxWaypoint{
+			Latitude:    fmt.Sprintf("%f", wp.Latitude),
+			Longitude:   fmt.Sprintf("%f", wp.Longitude),
+			Name:        wp.Name,
+			Description: wp.Description,
+			Type:        wp.Type,
+		}
+		for _, link := range wp.Links {
+			waypoint.Links = append(waypoint.Links, gpxLink{Href: link.Href, Text: link.Text})
+		}
+		file.Waypoints = append(file.Waypoints, waypoint)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("error writing GPX: %v", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(file); err != nil {
+		return fmt.Errorf("error writing GPX: %v", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("error writing GPX: %v", err)
+	}
+	return nil
+}
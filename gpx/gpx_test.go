@@ -0,0 +1,101 @@
+package gpx_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/tonyjhuang/reddit-to-gmap/gpx"
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+var restaurants = []maps.Restaurant{
+	{
+		Name:      "Joe's",
+		Upvotes:   515,
+		RedditUrl: "https://www.reddit.com/r/foodnyc/comments/a1/joes/",
+		GoogleMapsData: maps.GoogleMapsData{
+			Name:            "Joe's Pizza",
+			Type:            "Pizza restaurant",
+			Rating:          4.5,
+			UserRatingCount: 2271,
+			GoogleMapsUrl:   "https://www.google.com/maps/search/?api=1&query=Joe%27s",
+			Latitude:        40.7306,
+			Longitude:       -73.989,
+		},
+	},
+	// No coordinates
+	{Name: "Somewhere", Upvotes: 300},
+	// No Places name
+	{
+		Name:           "Duzan",
+		Upvotes:        120,
+		GoogleMapsData: maps.GoogleMapsData{Latitude: 40.767, Longitude: -73.921},
+	},
+}
+
+func TestRestaurants(t *testing.T) {
+	file := gpx.Restaurants("r/foodnyc", restaurants)
+	if len(file.Waypoints) != 2 {
+		t.Fatalf("got %d waypoints, want 2 (the one without coordinates is left out)", len(file.Waypoints))
+	}
+
+	joes := file.Waypoints[0]
+	if joes.Name != "Joe's Pizza" || joes.Type != "Pizza restaurant" || joes.Latitude != 40.7306 || joes.Longitude != -73.989 {
+		t.Errorf("waypoint = %+v", joes)
+	}
+	want := []gpx.Link{
+		{Href: "https://www.reddit.com/r/foodnyc/comments/a1/joes/", Text: "Reddit post"},
+		{Href: "https://www.google.com/maps/search/?api=1&query=Joe%27s", Text: "Google Maps"},
+	}
+	if len(joes.Links) != 2 || joes.Links[0] != want[0] || joes.Links[1] != want[1] {
+		t.Errorf("links = %+v, want %+v", joes.Links, want)
+	}
+	for _, want := range []string{"#1 · Pizza restaurant", "Rating: 4.5 (2271 reviews)", "Upvotes: 515", "Reddit: https://"} {
+		if !strings.Contains(joes.Description, want) {
+			t.Errorf("description = %q, want it to contain %q", joes.Description, want)
+		}
+	}
+
+	// The rank counts the restaurant left out, and the name falls back to
+	// the one from Reddit
+	duzan := file.Waypoints[1]
+	if duzan.Name != "Duzan" || !strings.HasPrefix(duzan.Description, "#3\n") || len(duzan.Links) != 0 {
+		t.Errorf("waypoint = %+v, want Duzan ranked #3 without links", duzan)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var b bytes.Buffer
+	if err := gpx.Restaurants("r/foodnyc", restaurants).Write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		XMLName   xml.Name
+		Version   string `xml:"version,attr"`
+		Name      string `xml:"metadata>name"`
+		Waypoints []struct {
+			Latitude  string `xml:"lat,attr"`
+			Longitude string `xml:"lon,attr"`
+			Name      string `xml:"name"`
+			Links     []struct {
+				Href string `xml:"href,attr"`
+			} `xml:"link"`
+		} `xml:"wpt"`
+	}
+	if err := xml.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid GPX: %v\n%s", err, b.String())
+	}
+	if doc.XMLName.Local != "gpx" || doc.Version != "1.1" || doc.Name != "r/foodnyc" || len(doc.Waypoints) != 2 {
+		t.Fatalf("document = %+v", doc)
+	}
+	wp := doc.Waypoints[0]
+	if wp.Latitude != "40.730600" || wp.Longitude != "-73.989000" || wp.Name != "Joe's Pizza" || len(wp.Links) != 2 {
+		t.Errorf("waypoint = %+v", wp)
+	}
+	if got := wp.Links[1].Href; got != "https://www.google.com/maps/search/?api=1&query=Joe%27s" {
+		t.Errorf("link = %q", got)
+	}
+}
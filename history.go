@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tonyjhuang/reddit-to-gmap/history"
+)
+
+var (
+	historyDir        string
+	historySubreddit  string
+	historyTimeRange  string
+	historyTop        int
+	historyRestaurant string
+	historyFormat     string
+	historyOut        string
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Build an all-time leaderboard and per-restaurant timelines from past CSV outputs",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataset, err := history.Load(historyDir, historySubreddit, historyTimeRange)
+		if err != nil {
+			return err
+		}
+		summary := dataset.Summarize(historyTop, historyRestaurant)
+
+		var w io.Writer = os.Stdout
+		if historyOut != "" {
+			file, err := os.Create(historyOut)
+			if err != nil {
+				return fmt.Errorf("error creating history output: %v", err)
+			}
+			defer file.Close()
+			w = file
+		}
+
+		switch historyFormat {
+		case "markdown":
+			return summary.WriteMarkdown(w)
+		case "json":
+			return summary.WriteJSON(w)
+		default:
+			return fmt.Errorf("unknown history format %q (expected markdown or json)", historyFormat)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().StringVar(&historyDir, "dir", "out", "Directory containing past CSV outputs")
+	historyCmd.Flags().StringVarP(&historySubreddit, "subreddit", "s", "", "Only include runs for this subreddit")
+	historyCmd.Flags().StringVarP(&historyTimeRange, "time-range", "t", "", "Only include runs with this time range (e.g. month)")
+	historyCmd.Flags().IntVar(&historyTop, "top", 25, "Number of entries in each list (0 means no limit)")
+	historyCmd.Flags().StringVar(&historyRestaurant, "restaurant", "", "Show timelines for restaurants whose name contains this text (defaults to the leaderboard)")
+	historyCmd.Flags().StringVarP(&historyFormat, "format", "f", "markdown", "Output format (markdown, json)")
+	historyCmd.Flags().StringVar(&historyOut, "out", "", "File to write the report to (defaults to stdout)")
+}
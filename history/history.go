@@ -0,0 +1,231 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/csv"
+	"github.com/tonyjhuang/reddit-to-gmap/diff"
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+// runFilePattern matches output file names such as foodnyc_20250602_month.csv
+// or, for a date window that isn't a calendar period, foodnyc_20250602_45d.csv.
+var runFilePattern = regexp.MustCompile(`^(.+)_(\d{8})_([a-z0-9]+)\.csv$`)
+
+// Run is a single past output file.
+type Run struct {
+	Name      string `json:"name"`
+	Subreddit string `json:"subreddit"`
+	TimeRange string `json:"time_range"`
+	// Date is the run date; the run covers the time range before it.
+	Date        time.Time         `json:"date"`
+	Restaurants []maps.Restaurant `json:"-"`
+}
+
+// Appearance is one restaurant's placement in one run.
+type Appearance struct {
+	Run             string    `json:"run"`
+	Date            time.Time `json:"date"`
+	Rank            int       `json:"rank"`
+	Upvotes         int       `json:"upvotes"`
+	Rating          float64   `json:"rating"`
+	UserRatingCount int       `json:"user_rating_count"`
+	RedditUrl       string    `json:"reddit_url"`
+}
+
+// Restaurant aggregates every appearance of a single place across runs.
+type Restaurant struct {
+	Key           string       `json:"key"`
+	Name          string       `json:"name"`
+	Type          string       `json:"type"`
+	GoogleMapsUrl string       `json:"google_maps_url"`
+	TotalUpvotes  int          `json:"total_upvotes"`
+	BestRank      int          `json:"best_rank"`
+	Months        int          `json:"months"`
+	Timeline      []Appearance `json:"timeline"`
+}
+
+// AverageRank returns the mean rank over all appearances.
+func (r *Restaurant) AverageRank() float64 {
+	if len(r.Timeline) == 0 {
+		return 0
+	}
+	total := 0
+	for _, a := range r.Timeline {
+		total += a.Rank
+	}
+	return float64(total) / float64(len(r.Timeline))
+}
+
+// Dataset is the unified view over all loaded runs.
+type Dataset struct {
+	Runs        []Run         `json:"runs"`
+	Restaurants []*Restaurant `json:"restaurants"`
+}
+
+// ParseRunName extracts the subreddit, run date and time range from an output
+// file name. Outputs are named after the day they were made, or for a date
+// window after the day it ended, and cover the time range before that date.
+func ParseRunName(filename string) (subreddit string, date time.Time, timeRange string, ok bool) {
+	m := runFilePattern.FindStringSubmatch(filepath.Base(filename))
+	if m == nil {
+		return "", time.Time{}, "", false
+	}
+	date, err := time.Parse("20060102", m[2])
+	if err != nil {
+		return "", time.Time{}, "", false
+	}
+	return m[1], date, m[3], true
+}
+
+// Load reads every output CSV in dir, optionally restricted to one subreddit
+// and time range, and builds a dataset ordered by run date.
+func Load(dir, subreddit, timeRange string) (*Dataset, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading output directory: %v", err)
+	}
+
+	var runs []Run
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		sub, date, tr, ok := ParseRunName(entry.Name())
+		if !ok {
+			continue
+		}
+		if subreddit != "" && !strings.EqualFold(sub, subreddit) {
+			continue
+		}
+		if timeRange != "" && tr != timeRange {
+			continue
+		}
+
+		restaurants, err := csv.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, Run{
+			Name:        strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())),
+			Subreddit:   sub,
+			TimeRange:   tr,
+			Date:        date,
+			Restaurants: restaurants,
+		})
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Date.Before(runs[j].Date)
+	})
+
+	return Build(runs), nil
+}
+
+// Build aggregates runs into a dataset. Runs must be ordered by date.
+func Build(runs []Run) *Dataset {
+	dataset := &Dataset{Runs: runs}
+	byKey := make(map[string]*Restaurant)
+	// Upvotes are summed once per Reddit post, since the same post can appear
+	// in more than one run when windows overlap.
+	postUpvotes := make(map[string]map[string]int)
+	months := make(map[string]map[string]struct{})
+
+	for _, run := range runs {
+		seen := make(map[string]struct{})
+		for i, r := range run.Restaurants {
+			key := diff.Key(r)
+			if _, found := seen[key]; found {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			restaurant, found := byKey[key]
+			if !found {
+				restaurant = &Restaurant{Key: key, BestRank: i + 1}
+				byKey[key] = restaurant
+				postUpvotes[key] = make(map[string]int)
+				months[key] = make(map[string]struct{})
+				dataset.Restaurants = append(dataset.Restaurants, restaurant)
+			}
+
+			// Later runs carry the freshest name, type and URL.
+			restaurant.Name = r.GoogleMapsData.Name
+			restaurant.Type = r.GoogleMapsData.Type
+			restaurant.GoogleMapsUrl = r.GoogleMapsData.GoogleMapsUrl
+			if i+1 < restaurant.BestRank {
+				restaurant.BestRank = i + 1
+			}
+			restaurant.Timeline = append(restaurant.Timeline, Appearance{
+				Run:             run.Name,
+				Date:            run.Date,
+				Rank:            i + 1,
+				Upvotes:         r.Upvotes,
+				Rating:          r.GoogleMapsData.Rating,
+				UserRatingCount: r.GoogleMapsData.UserRatingCount,
+				RedditUrl:       r.RedditUrl,
+			})
+
+			post := r.RedditUrl
+			if post == "" {
+				post = run.Name
+			}
+			if r.Upvotes > postUpvotes[key][post] {
+				postUpvotes[key][post] = r.Upvotes
+			}
+			months[key][run.Date.Format("2006-01")] = struct{}{}
+		}
+	}
+
+	for key, restaurant := range byKey {
+		for _, upvotes := range postUpvotes[key] {
+			restaurant.TotalUpvotes += upvotes
+		}
+		restaurant.Months = len(months[key])
+	}
+
+	return dataset
+}
+
+// Leaderboard returns restaurants ordered by total upvotes across all runs.
+func (d *Dataset) Leaderboard() []*Restaurant {
+	restaurants := append([]*Restaurant(nil), d.Restaurants...)
+	sort.SliceStable(restaurants, func(i, j int) bool {
+		if restaurants[i].TotalUpvotes != restaurants[j].TotalUpvotes {
+			return restaurants[i].TotalUpvotes > restaurants[j].TotalUpvotes
+		}
+		return restaurants[i].BestRank < restaurants[j].BestRank
+	})
+	return restaurants
+}
+
+// MostConsistent returns restaurants ordered by the number of months they
+// appeared in, breaking ties by average rank.
+func (d *Dataset) MostConsistent() []*Restaurant {
+	restaurants := append([]*Restaurant(nil), d.Restaurants...)
+	sort.SliceStable(restaurants, func(i, j int) bool {
+		if restaurants[i].Months != restaurants[j].Months {
+			return restaurants[i].Months > restaurants[j].Months
+		}
+		return restaurants[i].AverageRank() < restaurants[j].AverageRank()
+	})
+	return restaurants
+}
+
+// Find returns restaurants whose name contains query, case-insensitively.
+func (d *Dataset) Find(query string) []*Restaurant {
+	query = strings.ToLower(query)
+	var matches []*Restaurant
+	for _, r := range d.Restaurants {
+		if strings.Contains(strings.ToLower(r.Name), query) {
+			matches = append(matches, r)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,182 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+func date(t *testing.T, value string) time.Time {
+	t.Helper()
+	d, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// place returns a restaurant with a place ID, mentioned in a Reddit post.
+func place(id, name, post string, upvotes int) maps.Restaurant {
+	return maps.Restaurant{
+		Name:      name,
+		Upvotes:   upvotes,
+		RedditUrl: "https://www.reddit.com/r/foodnyc/comments/" + post + "/",
+		GoogleMapsData: maps.GoogleMapsData{
+			Name:          name,
+			GoogleMapsUrl: "https://www.google.com/maps/search/?api=1&query=x&query_place_id=" + id,
+		},
+	}
+}
+
+func TestParseRunName(t *testing.T) {
+	tests := []struct {
+		filename      string
+		wantSubreddit string
+		wantDate      string
+		wantTimeRange string
+		wantOK        bool
+	}{
+		{"foodnyc_20250602_month.csv", "foodnyc", "2025-06-02", "month", true},
+		{"out/food_nyc_20250602_week.csv", "food_nyc", "2025-06-02", "week", true},
+		{"foodnyc_20251016_45d.csv", "foodnyc", "2025-10-16", "45d", true},
+		{"foodnyc_20250602_month.md", "", "", "", false},
+		{"foodnyc_20250602_month_changes.md", "", "", "", false},
+		{"foodnyc_month.atom", "", "", "", false},
+		{"foodnyc_20251399_month.csv", "", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			subreddit, d, timeRange, ok := ParseRunName(tt.filename)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseRunName() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if subreddit != tt.wantSubreddit || d.Format(time.DateOnly) != tt.wantDate || timeRange != tt.wantTimeRange {
+				t.Errorf("ParseRunName() = %q, %v, %q", subreddit, d, timeRange)
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	runs := []Run{
+		{
+			Name: "foodnyc_20250602_month",
+			Date: date(t, "2025-06-02"),
+			Restaurants: []maps.Restaurant{
+				place("a", "A", "p1", 100),
+				place("b", "B", "p2", 80),
+			},
+		},
+		{
+			// A week window that overlaps the month: post p1 is counted again
+			// with more upvotes, and B is listed twice
+			Name: "foodnyc_20250609_week",
+			Date: date(t, "2025-06-09"),
+			Restaurants: []maps.Restaurant{
+				place("b", "B", "p3", 50),
+				place("a", "A", "p1", 120),
+				place("b", "B", "p2", 80),
+			},
+		},
+		{
+			Name: "foodnyc_20250702_month",
+			Date: date(t, "2025-07-02"),
+			Restaurants: []maps.Restaurant{
+				place("c", "C", "p4", 500),
+				place("a", "A renamed", "p5", 30),
+			},
+		},
+	}
+	dataset := Build(runs)
+
+	byName := make(map[string]*Restaurant)
+	for _, r := range dataset.Restaurants {
+		byName[r.Name] = r
+	}
+	tests := []struct {
+		name         string
+		totalUpvotes int
+		bestRank     int
+		months       int
+		ranks        []int
+	}{
+		// p1 once at its highest, plus p5
+		{"A renamed", 150, 1, 2, []int{1, 2, 2}},
+		// p2 once, plus p3; the second listing in a run is skipped
+		{"B", 130, 1, 1, []int{2, 1}},
+		{"C", 500, 1, 1, []int{1}},
+	}
+	if len(dataset.Restaurants) != len(tests) {
+		t.Fatalf("got %d restaurants, want %d", len(dataset.Restaurants), len(tests))
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := byName[tt.name]
+			if r == nil {
+				t.Fatalf("no restaurant named %q", tt.name)
+			}
+			if r.TotalUpvotes != tt.totalUpvotes {
+				t.Errorf("TotalUpvotes = %d, want %d", r.TotalUpvotes, tt.totalUpvotes)
+			}
+			if r.BestRank != tt.bestRank {
+				t.Errorf("BestRank = %d, want %d", r.BestRank, tt.bestRank)
+			}
+			if r.Months != tt.months {
+				t.Errorf("Months = %d, want %d", r.Months, tt.months)
+			}
+			var ranks []int
+			for _, a := range r.Timeline {
+				ranks = append(ranks, a.Rank)
+			}
+			if !slices.Equal(ranks, tt.ranks) {
+				t.Errorf("timeline ranks = %v, want %v", ranks, tt.ranks)
+			}
+		})
+	}
+
+	var leaderboard []string
+	for _, r := range dataset.Leaderboard() {
+		leaderboard = append(leaderboard, r.Name)
+	}
+	if want := []string{"C", "A renamed", "B"}; !slices.Equal(leaderboard, want) {
+		t.Errorf("Leaderboard() = %v, want %v", leaderboard, want)
+	}
+	if first := dataset.MostConsistent()[0]; first.Name != "A renamed" {
+		t.Errorf("MostConsistent()[0] = %q, want A renamed", first.Name)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"foodnyc_20250702_month.csv": "rank,name,maps_name,upvotes\n1,C,C,500\n",
+		"foodnyc_20250602_month.csv": "rank,name,maps_name,upvotes\n1,A,A,100\n",
+		"foodnyc_20250609_week.csv":  "rank,name,maps_name,upvotes\n1,B,B,50\n",
+		"other_20250602_month.csv":   "rank,name,maps_name,upvotes\n1,D,D,10\n",
+		"foodnyc_month.atom":         "<feed/>",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dataset, err := Load(dir, "FoodNYC", "month")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, run := range dataset.Runs {
+		names = append(names, run.Name)
+	}
+	if want := []string{"foodnyc_20250602_month", "foodnyc_20250702_month"}; !slices.Equal(names, want) {
+		t.Errorf("runs = %v, want %v in date order", names, want)
+	}
+}
@@ -0,0 +1,93 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Summary is the rendered view of a dataset: the top of each list plus the
+// timelines of selected restaurants.
+type Summary struct {
+	Runs           []Run         `json:"runs"`
+	Leaderboard    []*Restaurant `json:"leaderboard"`
+	MostConsistent []*Restaurant `json:"most_consistent"`
+	Timelines      []*Restaurant `json:"timelines"`
+}
+
+// Summarize keeps the top entries of each list. Timelines are included for
+// restaurants matching query, or for the leaderboard when query is empty.
+func (d *Dataset) Summarize(top int, query string) *Summary {
+	summary := &Summary{
+		Runs:           d.Runs,
+		Leaderboard:    truncate(d.Leaderboard(), top),
+		MostConsistent: truncate(d.MostConsistent(), top),
+	}
+	if query != "" {
+		summary.Timelines = d.Find(query)
+	} else {
+		summary.Timelines = summary.Leaderboard
+	}
+	return summary
+}
+
+func truncate(restaurants []*Restaurant, n int) []*Restaurant {
+	if n > 0 && len(restaurants) > n {
+		return restaurants[:n]
+	}
+	return restaurants
+}
+
+// WriteJSON writes the summary as indented JSON.
+func (s *Summary) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(s)
+}
+
+// WriteMarkdown writes the summary as Markdown tables.
+func (s *Summary) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("# Restaurant history\n\n")
+	if len(s.Runs) > 0 {
+		fmt.Fprintf(&b, "%d runs from %s to %s.\n", len(s.Runs),
+			s.Runs[0].Date.Format("2006-01-02"), s.Runs[len(s.Runs)-1].Date.Format("2006-01-02"))
+	} else {
+		b.WriteString("No runs found.\n")
+	}
+
+	b.WriteString("\n## All-time leaderboard\n\n| # | Restaurant | Type | Total upvotes | Best rank | Months |\n| --- | --- | --- | --- | --- | --- |\n")
+	for i, r := range s.Leaderboard {
+		fmt.Fprintf(&b, "| %d | %s | %s | %d | %d | %d |\n", i+1, link(r), r.Type, r.TotalUpvotes, r.BestRank, r.Months)
+	}
+
+	b.WriteString("\n## Most consistent\n\n| # | Restaurant | Months | Average rank | Best rank |\n| --- | --- | --- | --- | --- |\n")
+	for i, r := range s.MostConsistent {
+		fmt.Fprintf(&b, "| %d | %s | %d | %.1f | %d |\n", i+1, link(r), r.Months, r.AverageRank(), r.BestRank)
+	}
+
+	if len(s.Timelines) > 0 {
+		b.WriteString("\n## Timelines\n")
+		for _, r := range s.Timelines {
+			fmt.Fprintf(&b, "\n### %s\n\n| Run | Rank | Upvotes | Rating |\n| --- | --- | --- | --- |\n", link(r))
+			for _, a := range r.Timeline {
+				fmt.Fprintf(&b, "| %s | %d | %d | %.1f (%d reviews) |\n", a.Date.Format("2006-01-02"), a.Rank, a.Upvotes, a.Rating, a.UserRatingCount)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// link renders a restaurant's name as a Markdown link to its Google Maps page.
+func link(r *Restaurant) string {
+	name := strings.NewReplacer("|", `\|`, "[", `\[`, "]", `\]`).Replace(r.Name)
+	if r.GoogleMapsUrl == "" {
+		return name
+	}
+	return fmt.Sprintf("[%s](%s)", name, r.GoogleMapsUrl)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// dumpNumPosts is bound to ingest:dump's --num-posts, which has a different
+// default from the other pipeline commands.
+var dumpNumPosts int
+
+var ingestDumpCmd = &cobra.Command{
+	Use:   "ingest:dump",
+	Short: "Generate outputs from an offline Reddit dump instead of the Reddit API",
+	Long: `Read posts from a Reddit submissions dump (newline-delimited JSON, optionally
+zstd-compressed as .zst) instead of the Reddit API, and run them through the
+same extraction, Google Maps and output stages as generate-top-post-google-map-csv.
+This allows backfilling history for months the Reddit API no longer serves.`,
+	PreRunE: preparePipeline,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobOptions.NumPosts = dumpNumPosts
+		return runPipeline(cmd.Context(), "")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(ingestDumpCmd)
+	ingestDumpCmd.Flags().StringVar(&jobOptions.DumpFile, "file", "", "Path to the dump file (.zst or plain NDJSON) (required)")
+	ingestDumpCmd.Flags().StringVarP(&jobOptions.Subreddit, "subreddit", "s", "", "Subreddit to keep posts from (required)")
+	ingestDumpCmd.Flags().IntVarP(&dumpNumPosts, "num-posts", "n", 0, "Number of highest scoring posts to keep (0 means no limit)")
+	ingestDumpCmd.Flags().StringVarP(&jobOptions.MapsQueryHint, "maps-query-hint", "l", "", "Location hint for Google Maps queries (e.g. 'NYC', 'San Francisco')")
+	ingestDumpCmd.Flags().StringVar(&sinceFlag, "since", "", "Keep posts submitted on or after this date (YYYY-MM-DD, UTC)")
+	ingestDumpCmd.Flags().StringVar(&untilFlag, "until", "", "With --since, keep posts submitted before this date (YYYY-MM-DD, UTC; default: now)")
+	ingestDumpCmd.Flags().StringVar(&monthFlag, "month", "", "Keep posts submitted in a calendar month (YYYY-MM, or 'last' for the previous month); shorthand for --since/--until")
+	ingestDumpCmd.MarkFlagRequired("file")
+	ingestDumpCmd.MarkFlagRequired("subreddit")
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tonyjhuang/reddit-to-gmap/itinerary"
+	"github.com/tonyjhuang/reddit-to-gmap/markdown"
+	"github.com/tonyjhuang/reddit-to-gmap/output"
+)
+
+var (
+	itineraryStops    int
+	itineraryMinStops int
+	itineraryRadius   float64
+	itineraryTop      int
+	itineraryFormat   string
+	itineraryOut      string
+)
+
+var itineraryCmd = &cobra.Command{
+	Use:   "itinerary <run>",
+	Short: "Plan walking food crawls through a run's ranked restaurants",
+	Long: `Cluster a run's ranked restaurants by location and plan walking food crawls
+through each cluster. Each crawl starts at its best ranked restaurant and walks
+to the nearest stop left until it has --stops stops. The run is a CSV file
+written by this tool, a cache key of stored restaurant data (e.g.
+foodnyc_full_restaurants) or the ID of a completed run.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if itineraryStops < 2 || itineraryStops > itinerary.MaxStops {
+			return fmt.Errorf("--stops must be between 2 and %d", itinerary.MaxStops)
+		}
+		if itineraryMinStops < 1 || itineraryMinStops > itineraryStops {
+			return fmt.Errorf("--min-stops must be between 1 and --stops")
+		}
+		if itineraryRadius <= 0 {
+			return fmt.Errorf("--radius must be positive")
+		}
+
+		restaurants, err := loadRun(args[0])
+		if err != nil {
+			return err
+		}
+		if itineraryTop > 0 && len(restaurants) > itineraryTop {
+			restaurants = restaurants[:itineraryTop]
+		}
+
+		crawls := itinerary.Plan(restaurants, itinerary.Options{
+			Radius:   itineraryRadius,
+			Stops:    itineraryStops,
+			MinStops: itineraryMinStops,
+		})
+		slog.Info("Planned food crawls", "run", runName(args[0]), "restaurants", len(restaurants), "crawls", len(crawls))
+
+		dir, filename := output.Stdout, output.Stdout
+		if itineraryOut != "" {
+			dir, filename = filepath.Dir(itineraryOut), filepath.Base(itineraryOut)
+		}
+		file, err := output.Create(dir, filename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		title := "Food crawls from " + runName(args[0])
+		switch itineraryFormat {
+		case "markdown":
+			err = itinerary.Markdown(title, itineraryParameters(args[0], len(restaurants)), crawls).Write(file)
+		case "kml":
+			err = itinerary.KML(title, crawls).Write(file)
+		case "kmz":
+			err = itinerary.KML(title, crawls).WriteKMZ(file)
+		default:
+			err = fmt.Errorf("unknown itinerary format %q (expected markdown, kml or kmz)", itineraryFormat)
+		}
+		if err != nil {
+			return err
+		}
+		return file.Commit()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(itineraryCmd)
+	itineraryCmd.Flags().IntVarP(&itineraryStops, "stops", "k", 4, fmt.Sprintf("Most stops in a crawl (up to %d)", itinerary.MaxStops))
+	itineraryCmd.Flags().IntVar(&itineraryMinStops, "min-stops", 2, "Fewest stops in a crawl; restaurants with fewer neighbors are left out")
+	itineraryCmd.Flags().Float64Var(&itineraryRadius, "radius", 500, "Walking distance in meters within which restaurants are neighbors")
+	itineraryCmd.Flags().IntVar(&itineraryTop, "top", 0, "Only plan crawls through the top N restaurants (0 means all)")
+	itineraryCmd.Flags().StringVarP(&itineraryFormat, "format", "f", "markdown", "Output format (markdown, kml, kmz)")
+	itineraryCmd.Flags().StringVar(&itineraryOut, "out", "", "File to write the itinerary to (defaults to stdout)")
+}
+
+// itineraryParameters describes how the crawls were planned.
+func itineraryParameters(name string, restaurants int) []markdown.Parameter {
+	return []markdown.Parameter{
+		{Name: "Run", Value: name},
+		{Name: "Restaurants", Value: strconv.Itoa(restaurants)},
+		{Name: "Stops per crawl", Value: fmt.Sprintf("%d to %d", itineraryMinStops, itineraryStops)},
+		{Name: "Walking radius", Value: fmt.Sprintf("%g m", itineraryRadius)},
+		{Name: "Generated", Value: time.Now().Format(time.DateOnly)},
+	}
+}
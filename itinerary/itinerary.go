@@ -0,0 +1,193 @@
+// Package itinerary plans food crawls: walking routes through nearby ranked
+// restaurants. Restaurants are clustered with DBSCAN on their coordinates,
+// using great-circle distances, and each cluster is split into crawls whose
+// stops are ordered by a nearest-neighbor route.
+package itinerary
+
+import (
+	"math"
+	"slices"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+// Options configures how crawls are planned. The zero value of each field
+// has a usable default.
+type Options struct {
+	// Radius is the walking distance in meters within which restaurants are
+	// neighbors when clustering. The default is 500.
+	Radius float64
+	// Stops is the most stops in a crawl. The default is 4.
+	Stops int
+	// MinStops is the fewest stops in a crawl, and the fewest restaurants in
+	// a cluster. The default is 2.
+	MinStops int
+}
+
+// Stop is a restaurant on a crawl, with its overall rank.
+type Stop struct {
+	Rank       int
+	Restaurant maps.Restaurant
+	// Meters is the distance from the previous stop (0 for the first).
+	Meters float64
+}
+
+// Crawl is a walking route through nearby restaurants.
+type Crawl struct {
+	Stops []Stop
+	// Meters is the straight-line length of the route.
+	Meters float64
+}
+
+// walkingSpeed is an average walking pace in meters per minute (5 km/h).
+const walkingSpeed = 5000.0 / 60
+
+// WalkingMinutes estimates how long the route takes to walk.
+func (c Crawl) WalkingMinutes() int {
+	return int(math.Ceil(c.Meters / walkingSpeed))
+}
+
+func (o *Options) setDefaults() {
+	if o.Radius <= 0 {
+		o.Radius = 500
+	}
+	if o.Stops <= 0 {
+		o.Stops = 4
+	}
+	if o.MinStops <= 0 {
+		o.MinStops = 2
+	}
+	o.MinStops = min(o.MinStops, o.Stops)
+}
+
+// Plan plans crawls through restaurants, which must be in rank order.
+// Restaurants without coordinates or without enough neighbors are left out.
+// Crawls are ordered by their best ranked stop, and each starts at its best
+// ranked restaurant.
+func Plan(restaurants []maps.Restaurant, opts Options) []Crawl {
+	opts.setDefaults()
+
+	var stops []Stop
+	for i, r := range restaurants {
+		if r.GoogleMapsData.Latitude == 0 && r.GoogleMapsData.Longitude == 0 {
+			continue
+		}
+		stops = append(stops, Stop{Rank: i + 1, Restaurant: r})
+	}
+
+	var crawls []Crawl
+	for _, cluster := range dbscan(stops, opts.Radius, opts.MinStops) {
+		crawls = append(crawls, route(cluster, opts)...)
+	}
+	slices.SortFunc(crawls, func(a, b Crawl) int {
+		return a.Stops[0].Rank - b.Stops[0].Rank
+	})
+	return crawls
+}
+
+// dbscan groups stops into clusters of at least minPoints stops, each
+// reachable from another through stops within radius meters. Stops in no
+// cluster are dropped. Each cluster is in rank order.
+func dbscan(stops []Stop, radius float64, minPoints int) [][]Stop {
+	const unvisited, noise = 0, -1
+	labels := make([]int, len(stops))
+	neighbors := func(i int) []int {
+		var result []int
+		for j := range stops {
+			if distance(stops[i], stops[j]) <= radius {
+				result = append(result, j)
+			}
+		}
+		return result
+	}
+
+	cluster := 0
+	for i := range stops {
+		if labels[i] != unvisited {
+			continue
+		}
+		seeds := neighbors(i)
+		if len(seeds) < minPoints {
+			labels[i] = noise
+			continue
+		}
+
+		cluster++
+		labels[i] = cluster
+		for k := 0; k < len(seeds); k++ {
+			j := seeds[k]
+			if labels[j] == noise {
+				// A border point: in the cluster, but not expanded from
+				labels[j] = cluster
+			}
+			if labels[j] != unvisited {
+				continue
+			}
+			labels[j] = cluster
+			if more := neighbors(j); len(more) >= minPoints {
+				seeds = append(seeds, more...)
+			}
+		}
+	}
+
+	clusters := make([][]Stop, cluster)
+	for i, label := range labels {
+		if label > 0 {
+			clusters[label-1] = append(clusters[label-1], stops[i])
+		}
+	}
+	return clusters
+}
+
+// route splits a cluster into crawls. Each crawl starts at the best ranked
+// stop left and walks to the nearest stop left until it is full or the
+// nearest stop is farther than the radius, as a cluster can chain on for
+// kilometers. Crawls left with fewer than MinStops stops are dropped.
+func route(cluster []Stop, opts Options) []Crawl {
+	left := slices.Clone(cluster)
+	var crawls []Crawl
+	for len(left) >= opts.MinStops {
+		crawl := Crawl{Stops: []Stop{left[0]}}
+		left = left[1:]
+		for len(crawl.Stops) < opts.Stops && len(left) > 0 {
+			last := crawl.Stops[len(crawl.Stops)-1]
+			nearest := 0
+			for i := range left {
+				if distance(last, left[i]) < distance(last, left[nearest]) {
+					nearest = i
+				}
+			}
+			next := left[nearest]
+			next.Meters = distance(last, next)
+			if next.Meters > opts.Radius {
+				break
+			}
+			crawl.Stops = append(crawl.Stops, next)
+			crawl.Meters += next.Meters
+			left = slices.Delete(left, nearest, nearest+1)
+		}
+		if len(crawl.Stops) >= opts.MinStops {
+			crawls = append(crawls, crawl)
+		}
+	}
+	return crawls
+}
+
+// earthRadius is the mean radius of the Earth in meters.
+const earthRadius = 6371000.0
+
+func distance(a, b Stop) float64 {
+	da, db := a.Restaurant.GoogleMapsData, b.Restaurant.GoogleMapsData
+	return Distance(da.Latitude, da.Longitude, db.Latitude, db.Longitude)
+}
+
+// Distance returns the great-circle distance in meters between two points,
+// using the haversine formula.
+func Distance(lat1, lng1, lat2, lng2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLat := toRadians(lat2 - lat1)
+	dLng := toRadians(lng2 - lng1)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
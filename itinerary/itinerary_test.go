@@ -0,0 +1,194 @@
+package itinerary
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+// metersPerDegree is the length of a degree of longitude on the equator.
+const metersPerDegree = 2 * math.Pi * earthRadius / 360
+
+// line returns stops on the equator at the given distances in meters from a
+// point 1 km east of (0, 0), which would count as no coordinates, ranked in
+// the order given.
+func line(meters ...float64) []Stop {
+	stops := make([]Stop, len(meters))
+	for i, m := range meters {
+		stops[i] = Stop{
+			Rank: i + 1,
+			Restaurant: maps.Restaurant{
+				GoogleMapsData: maps.GoogleMapsData{Latitude: 0, Longitude: (1000 + m) / metersPerDegree},
+			},
+		}
+	}
+	return stops
+}
+
+func ranks(stops []Stop) []int {
+	result := make([]int, len(stops))
+	for i, s := range stops {
+		result[i] = s.Rank
+	}
+	return result
+}
+
+func TestDistance(t *testing.T) {
+	// A degree of latitude is about 111 km
+	if got := Distance(40, -74, 41, -74); math.Abs(got-111195) > 100 {
+		t.Errorf("Distance() = %.0f m, want about 111195 m", got)
+	}
+	if got := Distance(40.7, -74, 40.7, -74); got != 0 {
+		t.Errorf("Distance() to itself = %v, want 0", got)
+	}
+}
+
+func TestDBSCAN(t *testing.T) {
+	tests := []struct {
+		name      string
+		stops     []Stop
+		radius    float64
+		minPoints int
+		want      [][]int
+	}{
+		{
+			name:      "noise is dropped",
+			stops:     line(0, 100, 5000),
+			radius:    500,
+			minPoints: 2,
+			want:      [][]int{{1, 2}},
+		},
+		{
+			name:      "separate clusters",
+			stops:     line(0, 5000, 100, 5100),
+			radius:    500,
+			minPoints: 2,
+			want:      [][]int{{1, 3}, {2, 4}},
+		},
+		{
+			// The first stop has too few neighbors to be a core point, so it
+			// is noise until the second, a core point, reaches it
+			name:      "border points join the cluster",
+			stops:     line(0, 100, 200, 1000),
+			radius:    150,
+			minPoints: 3,
+			want:      [][]int{{1, 2, 3}},
+		},
+		{
+			name:      "chains through neighbors",
+			stops:     line(0, 400, 800, 1200, 1600),
+			radius:    500,
+			minPoints: 2,
+			want:      [][]int{{1, 2, 3, 4, 5}},
+		},
+		{
+			name:      "no clusters",
+			stops:     line(0, 1000),
+			radius:    500,
+			minPoints: 2,
+			want:      [][]int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusters := dbscan(tt.stops, tt.radius, tt.minPoints)
+			got := make([][]int, len(clusters))
+			for i, c := range clusters {
+				got[i] = ranks(c)
+			}
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("dbscan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoute(t *testing.T) {
+	tests := []struct {
+		name    string
+		cluster []Stop
+		opts    Options
+		want    [][]int
+	}{
+		{
+			name:    "nearest neighbor order",
+			cluster: line(0, 300, 100, 200),
+			opts:    Options{Radius: 500, Stops: 4, MinStops: 2},
+			want:    [][]int{{1, 3, 4, 2}},
+		},
+		{
+			name:    "splits into crawls of K stops and drops the leftover",
+			cluster: line(0, 10, 20, 30, 40),
+			opts:    Options{Radius: 500, Stops: 2, MinStops: 2},
+			want:    [][]int{{1, 2}, {3, 4}},
+		},
+		{
+			name:    "keeps a short last crawl with enough stops",
+			cluster: line(0, 10, 20, 30, 40),
+			opts:    Options{Radius: 500, Stops: 3, MinStops: 2},
+			want:    [][]int{{1, 2, 3}, {4, 5}},
+		},
+		{
+			// From the best stop, the nearest is behind it, and the rest of
+			// the chain is then out of walking distance
+			name:    "ends a crawl rather than walk past the radius",
+			cluster: line(400, 0, 800, 1200),
+			opts:    Options{Radius: 500, Stops: 4, MinStops: 2},
+			want:    [][]int{{1, 2}, {3, 4}},
+		},
+		{
+			name:    "drops a stop with no neighbor left",
+			cluster: line(400, 0, 800),
+			opts:    Options{Radius: 500, Stops: 4, MinStops: 2},
+			want:    [][]int{{1, 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crawls := route(tt.cluster, tt.opts)
+			got := make([][]int, len(crawls))
+			for i, c := range crawls {
+				got[i] = ranks(c.Stops)
+				for _, s := range c.Stops[1:] {
+					if s.Meters > tt.opts.Radius {
+						t.Errorf("crawl %d has a %.0f m hop", i+1, s.Meters)
+					}
+				}
+			}
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("route() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	var restaurants []maps.Restaurant
+	for _, s := range line(0, 5000, 100, 200, 9000) {
+		restaurants = append(restaurants, s.Restaurant)
+	}
+	// Restaurants without coordinates are left out
+	restaurants = append(restaurants, maps.Restaurant{Name: "Unknown"})
+
+	crawls := Plan(restaurants, Options{})
+	if len(crawls) != 1 {
+		t.Fatalf("Plan() = %d crawls, want 1", len(crawls))
+	}
+	c := crawls[0]
+	if got := ranks(c.Stops); !slices.Equal(got, []int{1, 3, 4}) {
+		t.Errorf("stops = %v, want [1 3 4]", got)
+	}
+	if math.Abs(c.Meters-200) > 1 {
+		t.Errorf("Meters = %.1f, want 200", c.Meters)
+	}
+
+	url := c.DirectionsURL()
+	for _, want := range []string{"travelmode=walking", "origin=0.000000%2C0.008993", "waypoints="} {
+		if !strings.Contains(url, want) {
+			t.Errorf("DirectionsURL() = %s, want it to contain %s", url, want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package itinerary
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/tonyjhuang/reddit-to-gmap/kml"
+	"github.com/tonyjhuang/reddit-to-gmap/markdown"
+)
+
+// MaxStops is the most stops a crawl can have and still fit in a Google
+// Maps directions link, which takes up to nine waypoints between the origin
+// and destination.
+const MaxStops = 11
+
+// Name describes a crawl by its position in the list and where it is.
+func (c Crawl) Name(number int) string {
+	name := fmt.Sprintf("Crawl %d", number)
+	if area := c.area(); area != "" {
+		name += ": " + area
+	}
+	return name
+}
+
+// area returns the neighborhood most of the crawl's stops are in, or the
+// borough if they have no neighborhood.
+func (c Crawl) area() string {
+	counts := make(map[string]int)
+	best := ""
+	for _, s := range c.Stops {
+		area := s.Restaurant.Neighborhood
+		if area == "" {
+			area = s.Restaurant.Borough
+		}
+		if area == "" {
+			continue
+		}
+		counts[area]++
+		if counts[area] > counts[best] {
+			best = area
+		}
+	}
+	return best
+}
+
+// Summary describes the length of a crawl.
+func (c Crawl) Summary() string {
+	return fmt.Sprintf("%d stops, %.1f km, about %d minutes on foot", len(c.Stops), c.Meters/1000, c.WalkingMinutes())
+}
+
+// DirectionsURL returns a Google Maps link to walking directions through the
+// crawl's stops in order. Crawls of more than MaxStops stops are cut short.
+func (c Crawl) DirectionsURL() string {
+	stops := c.Stops[:min(len(c.Stops), MaxStops)]
+	point := func(s Stop) string {
+		data := s.Restaurant.GoogleMapsData
+		return fmt.Sprintf("%f,%f", data.Latitude, data.Longitude)
+	}
+
+	params := url.Values{}
+	params.Set("api", "1")
+	params.Set("origin", point(stops[0]))
+	params.Set("destination", point(stops[len(stops)-1]))
+	if len(stops) > 2 {
+		waypoints := make([]string, 0, len(stops)-2)
+		for _, s := range stops[1 : len(stops)-1] {
+			waypoints = append(waypoints, point(s))
+		}
+		params.Set("waypoints", strings.Join(waypoints, "|"))
+	}
+	params.Set("travelmode", "walking")
+	return "https://www.google.com/maps/dir/?" + params.Encode()
+}
+
+// KML returns a document with a folder per crawl, which map apps show as
+// layers. Placemarks are numbered in walking order.
+func KML(title string, crawls []Crawl) *kml.Document {
+	doc := &kml.Document{Name: title}
+	for i, c := range crawls {
+		folder := kml.Folder{Name: fmt.Sprintf("%s (%s)", c.Name(i+1), c.Summary())}
+		for j, s := range c.Stops {
+			placemark, ok := kml.RestaurantPlacemark(s.Rank, s.Restaurant)
+			if !ok {
+				continue
+			}
+			placemark.Name = fmt.Sprintf("%d. %s", j+1, placemark.Name)
+			if j == len(c.Stops)-1 {
+				placemark.Description += fmt.Sprintf(`<br><a href="%s">Walking directions</a>`, html.EscapeString(c.DirectionsURL()))
+			}
+			folder.Placemarks = append(folder.Placemarks, placemark)
+		}
+		doc.Folders = append(doc.Folders, folder)
+	}
+	return doc
+}
+
+// Markdown returns a document with a table per crawl, its stops in walking
+// order, and a link to walking directions.
+func Markdown(title string, parameters []markdown.Parameter, crawls []Crawl) *markdown.Document {
+	doc := &markdown.Document{Title: title, Parameters: parameters}
+	for i, c := range crawls {
+		group := markdown.Group{
+			Name:    c.Name(i + 1),
+			Summary: c.Summary(),
+			Links:   []markdown.Link{{Text: "Walking directions", URL: c.DirectionsURL()}},
+		}
+		for _, s := range c.Stops {
+			group.Rows = append(group.Rows, markdown.Row{Rank: s.Rank, Restaurant: s.Restaurant})
+		}
+		doc.Groups = append(doc.Groups, group)
+	}
+	return doc
+}
@@ -0,0 +1,105 @@
+package job
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/atom"
+	"github.com/tonyjhuang/reddit-to-gmap/diff"
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+	"github.com/tonyjhuang/reddit-to-gmap/output"
+	"github.com/tonyjhuang/reddit-to-gmap/run"
+)
+
+// writeFeed adds the run's new restaurants to the Atom feed in the output
+// directory, creating the feed if needed, and returns its path.
+func writeFeed(o *Options, restaurants []maps.Restaurant) (string, error) {
+	filename := output.Filename(output.FeedFilenameTemplate, output.Vars{
+		Subreddit: o.Subreddit,
+		TimeRange: o.timeRange(),
+		Job:       o.Name,
+		Format:    "atom",
+	})
+
+	feed := o.newFeed()
+	if o.OutputDir != output.Stdout {
+		existing, err := readFeed(filepath.Join(o.OutputDir, filename))
+		if err != nil {
+			return "", err
+		}
+		if existing != nil {
+			feed = existing
+		}
+	}
+	added := o.addFeedRun(feed, restaurants)
+
+	file, err := output.Create(o.OutputDir, filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if err := feed.Write(file); err != nil {
+		return "", err
+	}
+	if err := file.Commit(); err != nil {
+		return "", err
+	}
+
+	slog.Info("Wrote output", "format", "atom", "restaurants", added, "entries", len(feed.Entries), "path", file.Path())
+	return file.Path(), nil
+}
+
+// readFeed reads the feed at path, or returns nil if there is none yet.
+func readFeed(path string) (*atom.Feed, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading Atom feed: %v", err)
+	}
+	defer file.Close()
+
+	feed, err := atom.Read(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	return feed, nil
+}
+
+// newFeed returns an empty feed for the run's subreddit and time range.
+func (o *Options) newFeed() *atom.Feed {
+	return atom.New(
+		fmt.Sprintf("urn:reddit-to-gmap:feed:%s:%s", o.Subreddit, o.timeRange()),
+		fmt.Sprintf("New top restaurants on r/%s", o.Subreddit),
+	)
+}
+
+// addFeedRun adds an entry to the feed for each restaurant that is new since
+// the previous run of the job, or for every restaurant if there is no
+// previous run, and returns the number added.
+func (o *Options) addFeedRun(feed *atom.Feed, restaurants []maps.Restaurant) int {
+	now := time.Now().UTC()
+	runID := o.Report.RunID
+	if runID == "" {
+		runID = run.IDAt(o.Subreddit, now)
+	}
+
+	added := diff.Entries(restaurants)
+	if previous := o.previousRun(); previous != nil {
+		added = diff.Compare(previous.ID, previous.Restaurants, runID, restaurants).Added
+	}
+
+	var entries []atom.Entry
+	for _, e := range added {
+		entries = append(entries, atom.RestaurantEntry(runID, e.Rank, restaurants[e.Rank-1], now))
+	}
+	feed.AddRun(runID, entries, now, o.FeedRuns)
+	return len(entries)
+}
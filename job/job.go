@@ -0,0 +1,271 @@
+// Package job runs the reddit-to-gmap pipeline: it fetches a subreddit's
+// posts, extracts the restaurants they mention with Gemini, looks them up on
+// Google Maps, ranks them and writes the output files.
+//
+// Everything a run needs is passed in Options, so jobs can run in parallel in
+// the same process:
+//
+//	result, err := job.Run(ctx, job.Options{
+//		Subreddit:     "foodnyc",
+//		NumPosts:      100,
+//		MapsQueryHint: "NYC",
+//		Credentials:   credentials,
+//	})
+package job
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/csv"
+	"github.com/tonyjhuang/reddit-to-gmap/filter"
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+	"github.com/tonyjhuang/reddit-to-gmap/notify"
+	"github.com/tonyjhuang/reddit-to-gmap/output"
+	"github.com/tonyjhuang/reddit-to-gmap/pipeline"
+	"github.com/tonyjhuang/reddit-to-gmap/reddit"
+	"github.com/tonyjhuang/reddit-to-gmap/report"
+	"github.com/tonyjhuang/reddit-to-gmap/run"
+)
+
+// Options configures a single run. The zero value of every field but
+// Subreddit and Credentials has a usable default.
+type Options struct {
+	// Name is the job's name, used for the {job} filename placeholder.
+	Name      string
+	Subreddit string
+	// NumPosts is the number of posts to fetch. For a listing the default is
+	// DefaultNumPosts; for a date window or a dump, 0 means no limit.
+	NumPosts int
+	// Listing selects the listing posts are fetched from. The default is the
+	// month's top posts.
+	Listing reddit.Listing
+	// Since and Until fetch the posts submitted in [Since, Until) instead of
+	// a listing.
+	Since time.Time
+	Until time.Time
+	// DumpFile reads posts from a Reddit dump instead of the Reddit API; see
+	// the dump package.
+	DumpFile string
+	// Filter drops posts before they are sent to Gemini.
+	Filter filter.Config
+	// MapsQueryHint is appended to Google Maps queries, e.g. "NYC".
+	MapsQueryHint string
+	// Boundaries are GeoJSON files of neighborhood boundaries that
+	// restaurants are assigned a neighborhood and borough from; see the geo
+	// package.
+	Boundaries []string
+	// Neighborhoods and Boroughs, if set, keep only the restaurants in one of
+	// them. Names are matched ignoring case.
+	Neighborhoods []string
+	Boroughs      []string
+	// NumOutput is the maximum number of restaurants to output (0 means no
+	// limit).
+	NumOutput int
+	// Stages are the pipeline stages to run, in order. The default is
+	// DefaultStages.
+	Stages []string
+	// UseCache starts the run after the last stage with cached output.
+	UseCache bool
+
+	// OutputDir, Filename and Formats control where outputs are written; see
+	// the output package for the filename placeholders.
+	OutputDir string
+	Filename  string
+	Formats   []string
+	// CSVSchema is the CSV column layout; CSVColumns takes precedence over it.
+	CSVSchema  string
+	CSVColumns []csv.Column
+	// GroupBy groups the Markdown output by one of GroupByValues. The
+	// default is "neighborhood".
+	GroupBy string
+	// FeedRuns is the number of runs the Atom feed keeps entries for. The
+	// default is DefaultFeedRuns.
+	FeedRuns int
+
+	// Notify are sent a summary of the run when it completes or fails, with
+	// its top NotifyTop restaurants (notify.DefaultTop if 0).
+	Notify    []notify.Sink
+	NotifyTop int
+
+	Credentials Credentials
+
+	// State checkpoints the run's progress so it can be resumed. Without it,
+	// nothing is checkpointed.
+	State *run.State
+	// Report records what the run did. Without it, Run starts a new report.
+	Report *report.Report
+
+	previous       *previousRun
+	previousLoaded bool
+}
+
+// Credentials are the API keys a run uses. The Reddit credentials are only
+// needed when posts are fetched from the Reddit API.
+type Credentials struct {
+	RedditClientID     string `env:"REDDIT_CLIENT_ID"`
+	RedditClientSecret string `env:"REDDIT_CLIENT_SECRET"`
+	GoogleMapsAPIKey   string `env:"GOOGLE_MAPS_API_KEY,required"`
+	GoogleGeminiAPIKey string `env:"GOOGLE_GEMINI_API_KEY,required"`
+	// Optional overrides for pointing at a local stand-in for the Reddit API.
+	RedditBaseURL  string `env:"REDDIT_BASE_URL"`
+	RedditTokenURL string `env:"REDDIT_TOKEN_URL"`
+}
+
+// Result is what a run produced.
+type Result struct {
+	// Status is how the run ended.
+	Status run.Status
+	// Output is the output of the last stage.
+	Output any
+	// Restaurants is the output of the last stage if it is a list of
+	// restaurants with Google Maps data, as it is for the default stages.
+	Restaurants []maps.Restaurant
+	// Files are the paths of the output files written.
+	Files  []string
+	Report *report.Report
+}
+
+// OutputFormats are the supported values of Options.Formats.
+var OutputFormats = []string{"csv", "json", "geojson", "kml", "kmz", "gpx", "atom", "markdown"}
+
+// DefaultNumPosts is the number of posts fetched from a listing when
+// Options.NumPosts is not set.
+const DefaultNumPosts = 10
+
+// DefaultFeedRuns is the number of runs the Atom feed keeps entries for when
+// Options.FeedRuns is not set.
+const DefaultFeedRuns = 12
+
+// Run runs the pipeline. When it returns, the outcome is recorded in the run
+// state, if there is one, the report is finished and written to the run's
+// directory, and notifications have been sent. If the run fails, the returned Result still holds the status,
+// the report and any files written.
+func Run(ctx context.Context, opts Options) (Result, error) {
+	opts.setDefaults()
+	if opts.State != nil {
+		opts.State.Job = opts.Name
+	}
+	result := Result{Report: opts.Report}
+	out, err := execute(ctx, &opts, &result)
+	if err == nil {
+		result.Output = out
+		result.Restaurants, _ = out.([]maps.Restaurant)
+		opts.saveResults(result.Restaurants)
+	}
+	result.Status = finish(ctx, &opts, err)
+	sendNotifications(ctx, &opts, &result)
+	return result, err
+}
+
+// execute validates the options, builds the stages and runs them.
+func execute(ctx context.Context, opts *Options, result *Result) (any, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	steps, err := buildSteps(opts, result)
+	if err != nil {
+		return nil, err
+	}
+	runner := &pipeline.Runner{
+		CacheKey: opts.cacheKey(),
+		UseCache: opts.UseCache,
+		State:    opts.State,
+		Report:   opts.Report,
+	}
+	return runner.Run(ctx, steps)
+}
+
+// finish records how a run ended in its state and report, and writes the
+// report to the run's directory.
+func finish(ctx context.Context, opts *Options, err error) run.Status {
+	status := run.StatusCompleted
+	switch {
+	case ctx.Err() != nil:
+		status = run.StatusInterrupted
+	case err != nil:
+		status = run.StatusFailed
+	}
+
+	opts.Report.Finish(string(status), err)
+	if opts.State != nil {
+		if saveErr := opts.State.Finish(status, err); saveErr != nil {
+			slog.Warn("Could not save run state", "run_id", opts.State.ID, "error", saveErr)
+		}
+		if writeErr := opts.Report.WriteFile(filepath.Join(opts.State.Dir(), run.ReportFile)); writeErr != nil {
+			slog.Warn("Could not write run report", "run_id", opts.State.ID, "error", writeErr)
+		}
+	}
+	return status
+}
+
+// setDefaults fills in the options that were left unset.
+func (o *Options) setDefaults() {
+	if o.NumPosts == 0 && o.Since.IsZero() && o.DumpFile == "" {
+		o.NumPosts = DefaultNumPosts
+	}
+	if o.Listing.Sort == "" {
+		o.Listing.Sort = reddit.ListingTop
+	}
+	if o.Listing.TimeRange == "" {
+		o.Listing.TimeRange = "month"
+	}
+	if len(o.Stages) == 0 {
+		o.Stages = DefaultStages
+	}
+	if o.OutputDir == "" {
+		o.OutputDir = output.DefaultDir
+	}
+	if o.Filename == "" {
+		o.Filename = output.DefaultFilenameTemplate
+	}
+	if len(o.Formats) == 0 {
+		o.Formats = []string{"csv"}
+	}
+	if o.GroupBy == "" {
+		o.GroupBy = "neighborhood"
+	}
+	if o.FeedRuns == 0 {
+		o.FeedRuns = DefaultFeedRuns
+	}
+	if o.CSVSchema == "" {
+		o.CSVSchema = string(csv.SchemaLegacy)
+	}
+	if o.Report == nil {
+		id := ""
+		if o.State != nil {
+			id = o.State.ID
+		}
+		o.Report = report.New(id, "")
+	}
+}
+
+// validate checks the options that can be checked before anything runs.
+// Stages check their own options when they are built.
+func (o *Options) validate() error {
+	if o.Subreddit == "" {
+		return fmt.Errorf("a subreddit is required")
+	}
+	if !o.Since.IsZero() {
+		if o.Until.IsZero() {
+			return fmt.Errorf("a date window requires an end date")
+		}
+		if !o.Since.Before(o.Until) {
+			return fmt.Errorf("--since must be before --until")
+		}
+	}
+	for _, format := range o.Formats {
+		if !slices.Contains(OutputFormats, format) {
+			return fmt.Errorf("unknown output format %q (expected one of %s)", format, strings.Join(OutputFormats, ", "))
+		}
+	}
+	if !slices.Contains(GroupByValues, o.GroupBy) {
+		return fmt.Errorf("invalid --group-by %q (expected one of %s)", o.GroupBy, strings.Join(GroupByValues, ", "))
+	}
+	return nil
+}
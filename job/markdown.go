@@ -0,0 +1,74 @@
+package job
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+	"github.com/tonyjhuang/reddit-to-gmap/markdown"
+)
+
+// GroupByValues are the supported values of Options.GroupBy.
+var GroupByValues = []string{"neighborhood", "borough", "none"}
+
+// markdownDocument returns the Markdown output: the ranked restaurants,
+// grouped as the options say, under a header of the run's parameters.
+func (o *Options) markdownDocument(restaurants []maps.Restaurant) *markdown.Document {
+	var groupBy func(maps.Restaurant) string
+	switch o.GroupBy {
+	case "neighborhood":
+		groupBy = func(r maps.Restaurant) string { return r.Neighborhood }
+	case "borough":
+		groupBy = func(r maps.Restaurant) string { return r.Borough }
+	}
+	return markdown.Restaurants(
+		fmt.Sprintf("Top restaurants on r/%s", o.Subreddit),
+		o.markdownParameters(len(restaurants)),
+		restaurants,
+		groupBy,
+	)
+}
+
+// markdownParameters describes how the run found its restaurants.
+func (o *Options) markdownParameters(restaurants int) []markdown.Parameter {
+	var params []markdown.Parameter
+	add := func(name, value string) {
+		if value != "" {
+			params = append(params, markdown.Parameter{Name: name, Value: value})
+		}
+	}
+
+	if o.Name != "" {
+		add("Job", o.Name)
+	}
+	add("Subreddit", "r/"+o.Subreddit)
+
+	var posts string
+	switch {
+	case o.DumpFile != "":
+		posts = "from a Reddit dump"
+	case !o.Since.IsZero():
+		// Until is exclusive, so show the last day in the window
+		posts = fmt.Sprintf("submitted %s to %s", o.Since.Format(time.DateOnly), o.Until.AddDate(0, 0, -1).Format(time.DateOnly))
+	case o.Listing.Query != "":
+		posts = fmt.Sprintf("%s search for %q, past %s", o.Listing.Sort, o.Listing.Query, o.Listing.TimeRange)
+	default:
+		posts = fmt.Sprintf("%s listing, past %s", o.Listing.Sort, o.Listing.TimeRange)
+	}
+	if o.NumPosts > 0 {
+		posts = fmt.Sprintf("%d posts, %s", o.NumPosts, posts)
+	}
+	add("Posts", posts)
+	if o.Filter.MinScore > 0 {
+		add("Minimum score", strconv.Itoa(o.Filter.MinScore))
+	}
+	add("Maps query hint", o.MapsQueryHint)
+	add("Neighborhoods", strings.Join(o.Neighborhoods, ", "))
+	add("Boroughs", strings.Join(o.Boroughs, ", "))
+	add("Restaurants", strconv.Itoa(restaurants))
+	add("Generated", time.Now().Format(time.DateOnly))
+	add("Run", o.Report.RunID)
+	return params
+}
@@ -0,0 +1,43 @@
+package job
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/notify"
+	"github.com/tonyjhuang/reddit-to-gmap/run"
+)
+
+// notifyTimeout bounds how long sending notifications may take.
+const notifyTimeout = time.Minute
+
+// sendNotifications sends a summary of a finished run to the options' sinks.
+// Interrupted runs are left out, as they are resumed later. Failing to send
+// a notification is logged rather than failing the run.
+func sendNotifications(ctx context.Context, o *Options, result *Result) {
+	if len(o.Notify) == 0 || result.Status == run.StatusInterrupted {
+		return
+	}
+
+	summary := notify.NewSummary(result.Report, result.Restaurants, o.NotifyTop)
+	summary.Job = o.Name
+	summary.Subreddit = o.Subreddit
+	summary.Files = result.Files
+	if previous := o.previousRun(); previous != nil && result.Restaurants != nil {
+		summary.CompareTo(previous.ID, previous.Restaurants, result.Restaurants)
+	}
+	if o.State != nil {
+		summary.AddFailures(o.State)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	for _, sink := range o.Notify {
+		if err := sink.Send(ctx, summary); err != nil {
+			slog.Warn("Could not send notification", "run_id", summary.RunID, "error", err)
+			continue
+		}
+		slog.Debug("Sent notification", "run_id", summary.RunID)
+	}
+}
@@ -0,0 +1,170 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/csv"
+	"github.com/tonyjhuang/reddit-to-gmap/geojson"
+	"github.com/tonyjhuang/reddit-to-gmap/gpx"
+	"github.com/tonyjhuang/reddit-to-gmap/kml"
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+	"github.com/tonyjhuang/reddit-to-gmap/output"
+)
+
+// extensions are the file extensions of the output formats, which fill in
+// the {format} filename placeholder.
+var extensions = map[string]string{
+	"csv":      "csv",
+	"json":     "json",
+	"geojson":  "geojson",
+	"kml":      "kml",
+	"kmz":      "kmz",
+	"gpx":      "gpx",
+	"atom":     "atom",
+	"markdown": "md",
+}
+
+// writeOutput writes restaurants in a single format to the file named by the
+// filename template and returns its path. The file only appears once it has
+// been fully written.
+func writeOutput(o *Options, format string, restaurants []maps.Restaurant) (string, error) {
+	if format == "atom" {
+		return writeFeed(o, restaurants)
+	}
+	file, err := output.Create(o.OutputDir, o.outputFilename(format))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if err := WriteRestaurants(file, format, o, restaurants); err != nil {
+		return "", err
+	}
+
+	if err := file.Commit(); err != nil {
+		return "", err
+	}
+
+	slog.Info("Wrote output", "format", format, "restaurants", len(restaurants), "path", file.Path())
+	return file.Path(), nil
+}
+
+// outputFilename returns the name of the run's output file in a format,
+// relative to the output directory.
+func (o *Options) outputFilename(format string) string {
+	return output.Filename(o.Filename, output.Vars{
+		Subreddit: o.Subreddit,
+		Date:      o.runDate(time.Now()).Format("20060102"),
+		TimeRange: o.timeRange(),
+		Job:       o.Name,
+		Format:    extensions[format],
+	})
+}
+
+// runDate returns the date the run's outputs are named after. Outputs are
+// named after the day they were made and cover the period before it, so the
+// monthly run on 2026-02-02 writes foodnyc_20260202_month.csv for January. A
+// run over a date window that has ended is named after its end, the day a
+// run would have covered it, so backfills sort among the other runs.
+func (o *Options) runDate(now time.Time) time.Time {
+	if !o.Since.IsZero() && o.Until.Before(now) {
+		return o.Until
+	}
+	return now
+}
+
+// timeRange names the period the run's posts cover, for output names: the
+// listing's time range, or for a date window "day", "week", "month" or
+// "year" if it is exactly one, else its length in days, e.g. "45d". Dumps
+// read without a window cover "all" of their posts.
+func (o *Options) timeRange() string {
+	since, until := o.Since, o.Until
+	switch {
+	case since.IsZero() && o.DumpFile != "":
+		return "all"
+	case since.IsZero():
+		return o.Listing.TimeRange
+	case until.Equal(since.AddDate(0, 0, 1)):
+		return "day"
+	case until.Equal(since.AddDate(0, 0, 7)):
+		return "week"
+	case since.Day() == 1 && until.Equal(since.AddDate(0, 1, 0)):
+		return "month"
+	case since.YearDay() == 1 && until.Equal(since.AddDate(1, 0, 0)):
+		return "year"
+	default:
+		return fmt.Sprintf("%dd", int(math.Ceil(until.Sub(since).Hours()/24)))
+	}
+}
+
+// WriteRestaurants writes restaurants in one of the OutputFormats, using the
+// CSV settings of the options.
+func WriteRestaurants(w io.Writer, format string, o *Options, restaurants []maps.Restaurant) error {
+	// Callers such as the HTTP API may pass options that were never run
+	opts := *o
+	opts.setDefaults()
+	o = &opts
+
+	switch format {
+	case "csv":
+		return writeCSV(w, o, restaurants)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(restaurants); err != nil {
+			return fmt.Errorf("error writing JSON: %v", err)
+		}
+		return nil
+	case "geojson":
+		return geojson.Restaurants(restaurants).Write(w)
+	case "kml":
+		return kml.Restaurants("r/"+o.Subreddit, restaurants).Write(w)
+	case "kmz":
+		return kml.Restaurants("r/"+o.Subreddit, restaurants).WriteKMZ(w)
+	case "gpx":
+		return gpx.Restaurants("r/"+o.Subreddit, restaurants).Write(w)
+	case "markdown":
+		return o.markdownDocument(restaurants).Write(w)
+	case "atom":
+		// Without the feed file, the feed holds just this run's entries
+		feed := o.newFeed()
+		o.addFeedRun(feed, restaurants)
+		return feed.Write(w)
+	default:
+		return fmt.Errorf("unknown output format %q (expected one of %s)", format, strings.Join(OutputFormats, ", "))
+	}
+}
+
+// writeCSV writes restaurants using the job's CSV columns or the selected schema.
+func writeCSV(w io.Writer, o *Options, restaurants []maps.Restaurant) error {
+	var layout csv.Layout
+	var err error
+	if len(o.CSVColumns) > 0 {
+		layout, err = csv.NewTemplateLayout(o.CSVColumns)
+	} else {
+		schema := o.CSVSchema
+		if schema == "" {
+			schema = string(csv.SchemaLegacy)
+		}
+		layout, err = csv.ParseSchema(schema)
+	}
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.WriteRestaurants(layout, restaurants); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("error writing CSV: %v", err)
+	}
+	return nil
+}
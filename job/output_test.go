@@ -0,0 +1,91 @@
+package job
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/reddit"
+)
+
+func TestOutputFilename(t *testing.T) {
+	today := time.Now().Format("20060102")
+	september := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"listing", Options{}, "foodnyc_" + today + "_month.csv"},
+		{"listing of the year", Options{Listing: reddit.Listing{Sort: "top", TimeRange: "year"}}, "foodnyc_" + today + "_year.csv"},
+		{
+			// Named as the monthly run on October 1st would have been
+			"month window",
+			Options{Since: september, Until: september.AddDate(0, 1, 0)},
+			"foodnyc_20251001_month.csv",
+		},
+		{"week window", Options{Since: september, Until: september.AddDate(0, 0, 7)}, "foodnyc_20250908_week.csv"},
+		{"other window", Options{Since: september, Until: september.AddDate(0, 0, 45)}, "foodnyc_20251016_45d.csv"},
+		{"dump", Options{DumpFile: "RS.zst"}, "foodnyc_" + today + "_all.csv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.Subreddit = "foodnyc"
+			opts.setDefaults()
+			if got := opts.outputFilename("csv"); got != tt.want {
+				t.Errorf("outputFilename() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviousOutputOfBackfill(t *testing.T) {
+	dir := t.TempDir()
+	csv := "rank,name,upvotes\n1,A,9\n"
+	for _, name := range []string{"foodnyc_20250902_month.csv", "foodnyc_20251102_month.csv", "foodnyc_20250902_week.csv"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(csv), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	september := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	// A backfill of September compares against the run that covered August,
+	// not the later one that covered October
+	opts := Options{Subreddit: "foodnyc", OutputDir: dir, Since: september, Until: september.AddDate(0, 1, 0)}
+	opts.setDefaults()
+	if previous := opts.previousOutput(); previous == nil || previous.ID != "foodnyc_20250902_month" {
+		t.Errorf("previousOutput() = %+v, want foodnyc_20250902_month", previous)
+	}
+
+	// A listing run compares against the latest one
+	opts = Options{Subreddit: "foodnyc", OutputDir: dir}
+	opts.setDefaults()
+	if previous := opts.previousOutput(); previous == nil || previous.ID != "foodnyc_20251102_month" {
+		t.Errorf("previousOutput() = %+v, want foodnyc_20251102_month", previous)
+	}
+}
+
+func TestRunDate(t *testing.T) {
+	now := time.Date(2026, 2, 2, 9, 0, 0, 0, time.UTC)
+	january := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		opts Options
+		want time.Time
+	}{
+		{"listing", Options{}, now},
+		{"ended window", Options{Since: january, Until: january.AddDate(0, 1, 0)}, january.AddDate(0, 1, 0)},
+		{"open window", Options{Since: january, Until: now.AddDate(0, 0, 1)}, now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.runDate(now); !got.Equal(tt.want) {
+				t.Errorf("runDate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
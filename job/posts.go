@@ -0,0 +1,141 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/tonyjhuang/reddit-to-gmap/dump"
+	"github.com/tonyjhuang/reddit-to-gmap/reddit"
+)
+
+// newRedditClient creates a Reddit client from the credentials.
+func newRedditClient(creds Credentials) (*reddit.Client, error) {
+	if creds.RedditClientID == "" || creds.RedditClientSecret == "" {
+		return nil, fmt.Errorf("REDDIT_CLIENT_ID and REDDIT_CLIENT_SECRET must be set to fetch posts from Reddit")
+	}
+
+	var opts []reddit.Option
+	if creds.RedditBaseURL != "" {
+		opts = append(opts, reddit.WithBaseURL(creds.RedditBaseURL))
+	}
+	if creds.RedditTokenURL != "" {
+		opts = append(opts, reddit.WithTokenURL(creds.RedditTokenURL))
+	}
+	return reddit.NewClient(creds.RedditClientID, creds.RedditClientSecret, opts...), nil
+}
+
+// cacheKey returns the cache key for the run's posts, which the keys of the
+// later cached stages start with. Top posts of the default month keep the
+// historical key of just the subreddit; other listings, time ranges, search
+// sorts and dumps get their own key so they don't overwrite each other.
+func (o *Options) cacheKey() string {
+	if o.DumpFile != "" {
+		key := o.Subreddit + "_dump"
+		if !o.Since.IsZero() {
+			key += fmt.Sprintf("_%s_%s", o.Since.Format("20060102"), o.Until.Format("20060102"))
+		}
+		return key
+	}
+	if !o.Since.IsZero() {
+		return fmt.Sprintf("%s_%s_%s", o.Subreddit, o.Since.Format("20060102"), o.Until.Format("20060102"))
+	}
+	l := o.Listing
+	if l.Sort == reddit.ListingTop && l.TimeRange == "month" {
+		return o.Subreddit
+	}
+	key := o.Subreddit + "_" + l.Sort
+	switch l.Sort {
+	case reddit.ListingTop, reddit.ListingControversial:
+		key += "_" + l.TimeRange
+	case reddit.ListingSearch:
+		// Reddit sorts searches by relevance unless told otherwise
+		searchSort := l.SearchSort
+		if searchSort == "" {
+			searchSort = "relevance"
+		}
+		key += "_" + l.TimeRange + "_" + searchSort
+	}
+	if l.Query != "" {
+		key += "_" + strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return '-'
+		}, l.Query)
+	}
+	return key
+}
+
+// filterKey returns the part of the cache keys of the stages after the
+// filter that depends on the filter settings, so that changing them doesn't
+// reuse restaurants extracted from differently filtered posts.
+func (o *Options) filterKey() string {
+	if key := o.Filter.Key(); key != "" {
+		return "_filter-" + key
+	}
+	return ""
+}
+
+// fetchPostsBetween fetches every post in the Since/Until window and keeps
+// the NumPosts highest scoring ones (0 means no limit).
+func fetchPostsBetween(ctx context.Context, client *reddit.Client, o *Options) ([]reddit.Post, error) {
+	if o.Listing.Sort != reddit.ListingTop || o.Listing.Query != "" {
+		return nil, fmt.Errorf("--since and --month fetch the new listing and cannot be combined with --listing or --query")
+	}
+
+	posts, err := client.GetPostsBetween(ctx, o.Subreddit, o.Since, o.Until)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching posts: %v", err)
+	}
+	total := len(posts)
+	posts = topPosts(posts, o.NumPosts)
+
+	slog.Info("Exported posts",
+		"subreddit", o.Subreddit,
+		"posts", len(posts),
+		"in_window", total,
+		"since", o.Since.Format(time.DateOnly),
+		"until", o.Until.Format(time.DateOnly))
+	return posts, nil
+}
+
+// readDump reads the subreddit's posts in the Since/Until window from the
+// dump file and keeps the NumPosts highest scoring ones (0 means no limit).
+func readDump(o *Options) ([]reddit.Post, error) {
+	posts, stats, err := dump.ReadFile(o.DumpFile, dump.Filter{
+		Subreddit: o.Subreddit,
+		Since:     o.Since,
+		Until:     o.Until,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stats.Malformed > 0 {
+		slog.Warn("Skipped malformed lines in dump", "file", o.DumpFile, "lines", stats.Malformed)
+	}
+	posts = topPosts(posts, o.NumPosts)
+
+	attrs := []any{"subreddit", o.Subreddit, "file", o.DumpFile, "posts", len(posts), "matched", stats.Matched, "lines", stats.Lines}
+	if !o.Since.IsZero() {
+		attrs = append(attrs, "since", o.Since.Format(time.DateOnly), "until", o.Until.Format(time.DateOnly))
+	}
+	slog.Info("Read posts from dump", attrs...)
+	return posts, nil
+}
+
+// topPosts sorts posts by score in descending order and keeps the first n
+// (0 means no limit).
+func topPosts(posts []reddit.Post, n int) []reddit.Post {
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].Data.Score > posts[j].Data.Score
+	})
+	if n > 0 && len(posts) > n {
+		posts = posts[:n]
+	}
+	return posts
+}
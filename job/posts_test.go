@@ -0,0 +1,62 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/reddit"
+)
+
+func TestCacheKey(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"top of the month", Options{Listing: reddit.Listing{Sort: "top", TimeRange: "month"}}, "foodnyc"},
+		{"defaults", Options{}, "foodnyc"},
+		{"top of the year", Options{Listing: reddit.Listing{Sort: "top", TimeRange: "year"}}, "foodnyc_top_year"},
+		{"top of all time", Options{Listing: reddit.Listing{Sort: "top", TimeRange: "all"}}, "foodnyc_top_all"},
+		{"controversial of the week", Options{Listing: reddit.Listing{Sort: "controversial", TimeRange: "week"}}, "foodnyc_controversial_week"},
+		{"controversial of the month", Options{Listing: reddit.Listing{Sort: "controversial", TimeRange: "month"}}, "foodnyc_controversial_month"},
+		{"hot", Options{Listing: reddit.Listing{Sort: "hot"}}, "foodnyc_hot"},
+		{"new ignores the time range", Options{Listing: reddit.Listing{Sort: "new", TimeRange: "year"}}, "foodnyc_new"},
+		{"rising", Options{Listing: reddit.Listing{Sort: "rising"}}, "foodnyc_rising"},
+		{
+			"search by relevance",
+			Options{Listing: reddit.Listing{Sort: "search", TimeRange: "year", Query: "Best Pizza?"}},
+			"foodnyc_search_year_relevance_best-pizza-",
+		},
+		{
+			"search by relevance, explicitly",
+			Options{Listing: reddit.Listing{Sort: "search", TimeRange: "year", Query: "Best Pizza?", SearchSort: "relevance"}},
+			"foodnyc_search_year_relevance_best-pizza-",
+		},
+		{
+			"search by new",
+			Options{Listing: reddit.Listing{Sort: "search", TimeRange: "year", Query: "Best Pizza?", SearchSort: "new"}},
+			"foodnyc_search_year_new_best-pizza-",
+		},
+		{
+			"search of the month",
+			Options{Listing: reddit.Listing{Sort: "search", Query: "pizza", SearchSort: "top"}},
+			"foodnyc_search_month_top_pizza",
+		},
+		{"date window", Options{Since: since, Until: until}, "foodnyc_20260101_20260201"},
+		{"dump", Options{DumpFile: "RS_2026-01.zst"}, "foodnyc_dump"},
+		{"dump window", Options{DumpFile: "RS_2026-01.zst", Since: since, Until: until}, "foodnyc_dump_20260101_20260201"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.Subreddit = "foodnyc"
+			opts.setDefaults()
+			if got := opts.cacheKey(); got != tt.want {
+				t.Errorf("cacheKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
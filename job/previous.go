@@ -0,0 +1,138 @@
+package job
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/csv"
+	"github.com/tonyjhuang/reddit-to-gmap/history"
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+	"github.com/tonyjhuang/reddit-to-gmap/output"
+	"github.com/tonyjhuang/reddit-to-gmap/run"
+)
+
+// resultsCheckpoint names the file in a run's directory holding its ranked
+// restaurants, which later runs of the same job are compared against.
+const resultsCheckpoint = "results"
+
+// previousRun is the most recent completed run of the same job.
+type previousRun struct {
+	ID          string
+	Restaurants []maps.Restaurant
+}
+
+// saveResults saves the ranked restaurants of a completed run in its
+// directory.
+func (o *Options) saveResults(restaurants []maps.Restaurant) {
+	if o.State == nil || restaurants == nil {
+		return
+	}
+	if err := run.Checkpoint(o.State, resultsCheckpoint, restaurants); err != nil {
+		slog.Warn("Could not save run results", "run_id", o.State.ID, "error", err)
+	}
+}
+
+// Results returns the ranked restaurants saved by a completed run, or nil if
+// it saved none.
+func Results(state *run.State) ([]maps.Restaurant, error) {
+	return run.Results[maps.Restaurant](state, resultsCheckpoint)
+}
+
+// previousRun returns the most recent completed run of the same job and
+// subreddit, or nil if there is none. Runs in the run directory that saved
+// their results are preferred; otherwise the most recent earlier CSV output
+// of the subreddit and time range is used, so that runs starting without the
+// run directory, such as in CI, still compare against the last output.
+func (o *Options) previousRun() *previousRun {
+	if o.previousLoaded {
+		return o.previous
+	}
+	o.previousLoaded = true
+	o.previous = o.previousState()
+	if o.previous == nil {
+		o.previous = o.previousOutput()
+	}
+	return o.previous
+}
+
+// previousState returns the most recent completed run of the same job and
+// subreddit in the run directory. Runs are only found when the options have
+// a run state.
+func (o *Options) previousState() *previousRun {
+	if o.State == nil {
+		return nil
+	}
+
+	states, err := run.List(filepath.Dir(o.State.Dir()))
+	if err != nil {
+		slog.Warn("Could not list previous runs", "error", err)
+		return nil
+	}
+	for _, state := range states {
+		if state.ID == o.State.ID || state.Status != run.StatusCompleted ||
+			state.Job != o.Name || state.Flags["subreddit"] != o.Subreddit {
+			continue
+		}
+		restaurants, err := Results(state)
+		if err != nil {
+			slog.Warn("Could not read previous run results", "run_id", state.ID, "error", err)
+			continue
+		}
+		if restaurants == nil {
+			continue
+		}
+		return &previousRun{ID: state.ID, Restaurants: restaurants}
+	}
+	return nil
+}
+
+// previousOutput returns the most recent CSV output in the output directory
+// with the run's subreddit and time range from before the run's date, other
+// than the one this run writes. Its ID is the file name without the extension.
+func (o *Options) previousOutput() *previousRun {
+	if o.OutputDir == "" || o.OutputDir == output.Stdout {
+		return nil
+	}
+	entries, err := os.ReadDir(o.OutputDir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Could not list previous outputs", "error", err)
+		}
+		return nil
+	}
+
+	current := filepath.Base(o.outputFilename("csv"))
+	runDate := o.runDate(time.Now()).Format("20060102")
+	var latest string
+	var latestDate time.Time
+	for _, entry := range entries {
+		name := entry.Name()
+		subreddit, date, timeRange, ok := history.ParseRunName(name)
+		if !ok || name == current || !strings.EqualFold(subreddit, o.Subreddit) || timeRange != o.timeRange() {
+			continue
+		}
+		// A backfill compares against the run before it, not the latest one
+		if date.Format("20060102") > runDate {
+			continue
+		}
+		// Names sort by date, so the later name wins a tie
+		if latest == "" || !date.Before(latestDate) {
+			latest, latestDate = name, date
+		}
+	}
+	if latest == "" {
+		return nil
+	}
+
+	restaurants, err := csv.ReadFile(filepath.Join(o.OutputDir, latest))
+	if err != nil {
+		slog.Warn("Could not read previous output", "path", latest, "error", err)
+		return nil
+	}
+	return &previousRun{ID: strings.TrimSuffix(latest, filepath.Ext(latest)), Restaurants: restaurants}
+}
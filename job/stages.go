@@ -0,0 +1,408 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/filter"
+	"github.com/tonyjhuang/reddit-to-gmap/gemini"
+	"github.com/tonyjhuang/reddit-to-gmap/geo"
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+	"github.com/tonyjhuang/reddit-to-gmap/pipeline"
+	"github.com/tonyjhuang/reddit-to-gmap/reddit"
+	"github.com/tonyjhuang/reddit-to-gmap/report"
+)
+
+// Names of the pipeline stages, as used in Options.Stages, the run state and
+// the report.
+const (
+	PostsStage           = "posts"
+	FilterStage          = "filter"
+	RestaurantsStage     = "restaurants"
+	FullRestaurantsStage = "full_restaurants"
+	LocateStage          = "locate"
+	RankStage            = "rank"
+	OutputStage          = "output"
+)
+
+// DefaultStages is the pipeline run when Options.Stages is not set.
+var DefaultStages = []string{PostsStage, FilterStage, RestaurantsStage, FullRestaurantsStage, LocateStage, RankStage, OutputStage}
+
+// StagesThrough returns the default stages up to and including the named one.
+func StagesThrough(name string) []string {
+	i := slices.Index(DefaultStages, name)
+	return slices.Clone(DefaultStages[:i+1])
+}
+
+// stageFactories builds each stage that can be named in Options.Stages,
+// along with its caching, retry and batching options. A new stage is added
+// here and can then be put anywhere in the pipeline its input and output
+// types allow.
+var stageFactories = map[string]func(o *Options, result *Result) (pipeline.Step, error){
+	PostsStage: func(o *Options, result *Result) (pipeline.Step, error) {
+		return pipeline.New[pipeline.None, []reddit.Post](&postsStep{options: o}, pipeline.Cache("")), nil
+	},
+	FilterStage: func(o *Options, result *Result) (pipeline.Step, error) {
+		f, err := filter.New(o.Filter)
+		if err != nil {
+			return pipeline.Step{}, err
+		}
+		return pipeline.New[[]reddit.Post, []reddit.Post](&filterStep{filter: f}), nil
+	},
+	RestaurantsStage: func(o *Options, result *Result) (pipeline.Step, error) {
+		// Gemini takes up to 100 posts per request
+		return pipeline.NewBatched[reddit.Post, gemini.Restaurant](&restaurantsStep{options: o},
+			pipeline.Cache(o.filterKey()+"_restaurants"),
+			pipeline.BatchSize(100),
+			pipeline.Retry(2, 5*time.Second)), nil
+	},
+	FullRestaurantsStage: func(o *Options, result *Result) (pipeline.Step, error) {
+		// Look up one restaurant at a time, 2 seconds apart, and carry on past
+		// the ones that fail
+		return pipeline.NewBatched[gemini.Restaurant, maps.Restaurant](&fullRestaurantsStep{options: o},
+			pipeline.Cache(o.filterKey()+"_full_restaurants"),
+			pipeline.Pace(2*time.Second),
+			pipeline.Retry(1, 2*time.Second),
+			pipeline.TolerateFailures()), nil
+	},
+	LocateStage: func(o *Options, result *Result) (pipeline.Step, error) {
+		if len(o.Boroughs) > 0 && len(o.Boundaries) == 0 {
+			// Boroughs only come from boundaries, so nothing would match
+			return pipeline.Step{}, fmt.Errorf("--borough requires --boundaries")
+		}
+		step := &locateStep{neighborhoods: o.Neighborhoods, boroughs: o.Boroughs}
+		if len(o.Boundaries) > 0 {
+			index, err := geo.Load(o.Boundaries...)
+			if err != nil {
+				return pipeline.Step{}, err
+			}
+			step.index = index
+		}
+		return pipeline.New[[]maps.Restaurant, []maps.Restaurant](step), nil
+	},
+	RankStage: func(o *Options, result *Result) (pipeline.Step, error) {
+		return pipeline.New[[]maps.Restaurant, []maps.Restaurant](&rankStep{limit: o.NumOutput}), nil
+	},
+	OutputStage: func(o *Options, result *Result) (pipeline.Step, error) {
+		return pipeline.New[[]maps.Restaurant, []maps.Restaurant](&outputStep{options: o, result: result}), nil
+	},
+}
+
+// buildSteps builds the stages named in the options in order and checks
+// that they fit together.
+func buildSteps(o *Options, result *Result) ([]pipeline.Step, error) {
+	steps := make([]pipeline.Step, 0, len(o.Stages))
+	for _, name := range o.Stages {
+		factory, ok := stageFactories[name]
+		if !ok {
+			known := make([]string, 0, len(stageFactories))
+			for name := range stageFactories {
+				known = append(known, name)
+			}
+			slices.Sort(known)
+			return nil, fmt.Errorf("unknown stage %q (expected one of %s)", name, strings.Join(known, ", "))
+		}
+		step, err := factory(o, result)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	if err := pipeline.Validate(steps); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// postsStep fetches posts from Reddit, or reads them from the dump given with
+// --file.
+type postsStep struct {
+	options *Options
+	client  *reddit.Client
+}
+
+func (s *postsStep) Name() string { return PostsStage }
+
+func (s *postsStep) Run(ctx context.Context, _ pipeline.None) ([]reddit.Post, error) {
+	o := s.options
+	if o.DumpFile != "" {
+		return readDump(o)
+	}
+	client, err := newRedditClient(o.Credentials)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+
+	if !o.Since.IsZero() {
+		return fetchPostsBetween(ctx, client, o)
+	}
+	posts, err := client.GetListing(ctx, o.Subreddit, o.NumPosts, o.Listing)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching posts: %v", err)
+	}
+	slog.Info("Exported posts", "subreddit", o.Subreddit, "posts", len(posts), "listing", o.Listing.Sort, "time_range", o.Listing.TimeRange)
+	return posts, nil
+}
+
+func (s *postsStep) Report(r *report.Report) {
+	if s.client != nil {
+		r.APICalls.Reddit += s.client.Requests()
+	}
+}
+
+// filterStep drops posts that are not worth sending to Gemini.
+type filterStep struct {
+	filter *filter.Filter
+	result *filter.Result
+}
+
+func (s *filterStep) Name() string { return FilterStage }
+
+func (s *filterStep) Run(ctx context.Context, posts []reddit.Post) ([]reddit.Post, error) {
+	s.result = s.filter.Apply(posts)
+	slog.Info("Filtered posts", "summary", s.result.Summary())
+	return s.result.Kept, nil
+}
+
+func (s *filterStep) Report(r *report.Report) {
+	if s.result == nil {
+		return
+	}
+	r.AddDrops(s.result.Dropped)
+}
+
+// restaurantsStep extracts restaurants from posts with Gemini.
+type restaurantsStep struct {
+	options *Options
+	client  *gemini.Client
+}
+
+func (s *restaurantsStep) Name() string { return RestaurantsStage }
+
+func (s *restaurantsStep) Key(post reddit.Post) string { return post.Data.Permalink }
+
+func (s *restaurantsStep) Process(ctx context.Context, posts []reddit.Post) ([]gemini.Restaurant, error) {
+	if s.client == nil {
+		client, err := gemini.NewClient(ctx, s.options.Credentials.GoogleGeminiAPIKey)
+		if err != nil {
+			return nil, fmt.Errorf("error creating Gemini client: %v", err)
+		}
+		s.client = client
+	}
+
+	restaurants, err := s.client.ToRestaurantData(ctx, posts)
+	if err != nil {
+		return nil, fmt.Errorf("error processing posts chunk with Gemini: %v", err)
+	}
+	addPostDetails(restaurants, posts)
+	return restaurants, nil
+}
+
+func (s *restaurantsStep) Combine(restaurants []gemini.Restaurant) []gemini.Restaurant {
+	// Sort all restaurants by upvotes in descending order
+	sort.Slice(restaurants, func(i, j int) bool {
+		return restaurants[i].Upvotes > restaurants[j].Upvotes
+	})
+	uniqueRestaurants := dedupeRestaurants(restaurants)
+	slog.Info("Exported restaurants", "subreddit", s.options.Subreddit, "restaurants", len(uniqueRestaurants))
+	return uniqueRestaurants
+}
+
+func (s *restaurantsStep) Report(r *report.Report) {
+	if s.client == nil {
+		return
+	}
+	usage := s.client.Usage()
+	r.APICalls.Gemini += usage.Requests
+	r.GeminiTokens.Input += usage.InputTokens
+	r.GeminiTokens.Output += usage.OutputTokens
+}
+
+func (s *restaurantsStep) Close() error {
+	if s.client != nil {
+		s.client.Close()
+	}
+	return nil
+}
+
+// fullRestaurantsStep looks restaurants up on Google Maps to add canonical
+// links, ratings and addresses.
+type fullRestaurantsStep struct {
+	options *Options
+	client  *maps.Client
+}
+
+func (s *fullRestaurantsStep) Name() string { return FullRestaurantsStage }
+
+func (s *fullRestaurantsStep) Key(restaurant gemini.Restaurant) string { return restaurant.Name }
+
+func (s *fullRestaurantsStep) Process(ctx context.Context, restaurants []gemini.Restaurant) ([]maps.Restaurant, error) {
+	if s.client == nil {
+		client, err := maps.NewClient(ctx, s.options.Credentials.GoogleMapsAPIKey)
+		if err != nil {
+			return nil, fmt.Errorf("error creating Maps client: %v", err)
+		}
+		s.client = client
+	}
+
+	var results []maps.Restaurant
+	for _, restaurant := range restaurants {
+		result, err := s.client.FetchGoogleMapsLink(ctx, &restaurant, s.options.MapsQueryHint)
+		if err != nil {
+			return nil, err
+		}
+		if result != nil {
+			results = append(results, *result)
+		}
+	}
+	return results, nil
+}
+
+func (s *fullRestaurantsStep) Combine(restaurants []maps.Restaurant) []maps.Restaurant {
+	slog.Info("Exported restaurants with Google Maps data", "subreddit", s.options.Subreddit, "restaurants", len(restaurants))
+	return restaurants
+}
+
+func (s *fullRestaurantsStep) Report(r *report.Report) {
+	if s.client != nil {
+		r.APICalls.Places += s.client.Requests()
+	}
+}
+
+func (s *fullRestaurantsStep) Close() error {
+	if s.client != nil {
+		s.client.Close()
+	}
+	return nil
+}
+
+// locateStep assigns restaurants a neighborhood and borough from the
+// boundary files, if there are any, and keeps only those in the selected
+// neighborhoods and boroughs.
+type locateStep struct {
+	index         *geo.Index
+	neighborhoods []string
+	boroughs      []string
+}
+
+func (s *locateStep) Name() string { return LocateStage }
+
+func (s *locateStep) Run(ctx context.Context, restaurants []maps.Restaurant) ([]maps.Restaurant, error) {
+	if s.index != nil {
+		located := s.index.Assign(restaurants)
+		slog.Info("Located restaurants", "located", located, "restaurants", len(restaurants), "areas", s.index.Len())
+	}
+	if len(s.neighborhoods) == 0 && len(s.boroughs) == 0 {
+		return restaurants, nil
+	}
+
+	kept := slices.DeleteFunc(slices.Clone(restaurants), func(r maps.Restaurant) bool {
+		return (len(s.neighborhoods) > 0 && !containsFold(s.neighborhoods, r.Neighborhood)) ||
+			(len(s.boroughs) > 0 && !containsFold(s.boroughs, r.Borough))
+	})
+	slog.Info("Filtered restaurants by area", "kept", len(kept), "dropped", len(restaurants)-len(kept))
+	return kept, nil
+}
+
+// containsFold reports whether values contains s, ignoring case.
+func containsFold(values []string, s string) bool {
+	return slices.ContainsFunc(values, func(v string) bool {
+		return strings.EqualFold(v, s)
+	})
+}
+
+// rankStep sorts restaurants by upvotes and keeps the top ones (0 means no
+// limit).
+type rankStep struct {
+	limit int
+}
+
+func (s *rankStep) Name() string { return RankStage }
+
+func (s *rankStep) Run(ctx context.Context, restaurants []maps.Restaurant) ([]maps.Restaurant, error) {
+	// Sort restaurants by upvotes in descending order
+	sort.Slice(restaurants, func(i, j int) bool {
+		return restaurants[i].Upvotes > restaurants[j].Upvotes
+	})
+
+	if s.limit > 0 && len(restaurants) > s.limit {
+		restaurants = restaurants[:s.limit]
+	}
+	return restaurants, nil
+}
+
+// outputStep writes restaurants in every requested format.
+type outputStep struct {
+	options *Options
+	result  *Result
+}
+
+func (s *outputStep) Name() string { return OutputStage }
+
+func (s *outputStep) Run(ctx context.Context, restaurants []maps.Restaurant) ([]maps.Restaurant, error) {
+	for _, format := range s.options.Formats {
+		path, err := writeOutput(s.options, format, restaurants)
+		if err != nil {
+			return nil, err
+		}
+		s.result.Files = append(s.result.Files, path)
+	}
+	return restaurants, nil
+}
+
+// addPostDetails copies details of the post each restaurant was extracted
+// from, such as its title, flair, date and photos, onto the restaurant.
+func addPostDetails(restaurants []gemini.Restaurant, posts []reddit.Post) {
+	byPermalink := make(map[string]reddit.PostData, len(posts))
+	for _, post := range posts {
+		byPermalink[post.Data.Permalink] = post.Data
+	}
+	for i := range restaurants {
+		post, found := byPermalink[restaurants[i].RedditUrl]
+		if !found {
+			continue
+		}
+		restaurants[i].PostID = post.ID
+		restaurants[i].PostTitle = post.Title
+		restaurants[i].PostFlair = post.Flair
+		if post.CreatedUTC > 0 {
+			restaurants[i].PostedAt = post.Created()
+		}
+		restaurants[i].PhotoURLs = post.ImageURLs()
+	}
+}
+
+// dedupeRestaurants removes duplicate Restaurant entries based on the Name field.
+// It preserves the order of the first occurrence of each unique restaurant and
+// records how many posts mentioned it in Mentions.
+// It returns a new slice containing only the unique restaurants.
+func dedupeRestaurants(restaurants []gemini.Restaurant) []gemini.Restaurant {
+	seen := make(map[string]int)
+
+	// Initialize a new slice to store the unique restaurants.
+	uniqueRestaurants := make([]gemini.Restaurant, 0, len(restaurants)/2) // Example capacity
+
+	for _, r := range restaurants {
+		// Check if we've already seen a restaurant with this name
+		if i, found := seen[r.Name]; !found {
+			// If this name hasn't been seen before:
+			// 1. Remember its position in the result slice.
+			seen[r.Name] = len(uniqueRestaurants)
+			// 2. Append the current restaurant to our result slice.
+			r.Mentions = 1
+			uniqueRestaurants = append(uniqueRestaurants, r)
+		} else {
+			// If the name was already 'found' in the 'seen' map, we drop this
+			// duplicate and count it as another mention of the first one.
+			uniqueRestaurants[i].Mentions++
+		}
+	}
+
+	return uniqueRestaurants
+}
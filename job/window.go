@@ -0,0 +1,50 @@
+package job
+
+import (
+	"fmt"
+	"time"
+)
+
+// ParseWindow parses a date window given as a month (YYYY-MM, or "last" for
+// the month before now) or as a since date with an optional until date
+// (YYYY-MM-DD, UTC; until is exclusive and defaults to the end of today). It
+// returns zero times if none are set, meaning posts come from a listing.
+func ParseWindow(since, until, month string, now time.Time) (time.Time, time.Time, error) {
+	if month != "" {
+		if since != "" || until != "" {
+			return time.Time{}, time.Time{}, fmt.Errorf("--month cannot be combined with --since or --until")
+		}
+		var start time.Time
+		if month == "last" {
+			start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+		} else {
+			var err error
+			if start, err = time.Parse("2006-01", month); err != nil {
+				return time.Time{}, time.Time{}, fmt.Errorf("invalid --month %q (expected YYYY-MM or last)", month)
+			}
+		}
+		return start, start.AddDate(0, 1, 0), nil
+	}
+
+	if since == "" {
+		if until != "" {
+			return time.Time{}, time.Time{}, fmt.Errorf("--until requires --since")
+		}
+		return time.Time{}, time.Time{}, nil
+	}
+
+	start, err := time.Parse(time.DateOnly, since)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid --since %q (expected YYYY-MM-DD)", since)
+	}
+	end := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if until != "" {
+		if end, err = time.Parse(time.DateOnly, until); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --until %q (expected YYYY-MM-DD)", until)
+		}
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("--since must be before --until")
+	}
+	return start, end, nil
+}
@@ -0,0 +1,158 @@
+// Package kml writes restaurants as a KML document, which Google My Maps,
+// Google Earth and most map apps can import, or as a KMZ archive, which
+// Organic Maps and OsmAnd import as a bookmark list.
+package kml
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+// Document is a KML document. Each folder shows up as a layer.
+type Document struct {
+	Name    string
+	Folders []Folder
+}
+
+// Folder is a named group of placemarks.
+type Folder struct {
+	Name       string
+	Placemarks []Placemark
+}
+
+// Placemark is a point on the map. Description may contain HTML, so text in
+// it must be escaped.
+type Placemark struct {
+	Name        string
+	Description string
+	Latitude    float64
+	Longitude   float64
+}
+
+// Restaurants returns a document with a single folder holding a placemark
+// per restaurant, in rank order. Restaurants without coordinates are left out.
+func Restaurants(name string, restaurants []maps.Restaurant) *Document {
+	folder := Folder{Name: name}
+	for i, r := range restaurants {
+		if placemark, ok := RestaurantPlacemark(i+1, r); ok {
+			folder.Placemarks = append(folder.Placemarks, placemark)
+		}
+	}
+	return &Document{Name: name, Folders: []Folder{folder}}
+}
+
+// RestaurantPlacemark returns the placemark of a ranked restaurant, or false
+// if it has no coordinates.
+func RestaurantPlacemark(rank int, r maps.Restaurant) (Placemark, bool) {
+	data := r.GoogleMapsData
+	if data.Latitude == 0 && data.Longitude == 0 {
+		return Placemark{}, false
+	}
+
+	var description strings.Builder
+	fmt.Fprintf(&description, "#%d", rank)
+	if data.Type != "" {
+		fmt.Fprintf(&description, " · %s", html.EscapeString(data.Type))
+	}
+	fmt.Fprintf(&description, "<br>Rating: %.1f (%d reviews)<br>Upvotes: %d", data.Rating, data.UserRatingCount, r.Upvotes)
+	if data.GoogleMapsUrl != "" {
+		fmt.Fprintf(&description, `<br><a href="%s">Google Maps</a>`, html.EscapeString(data.GoogleMapsUrl))
+	}
+	if r.RedditUrl != "" {
+		fmt.Fprintf(&description, `<br><a href="%s">Reddit post</a>`, html.EscapeString(r.RedditUrl))
+	}
+
+	name := data.Name
+	if name == "" {
+		name = r.Name
+	}
+
+	return Placemark{
+		Name:        name,
+		Description: description.String(),
+		Latitude:    data.Latitude,
+		Longitude:   data.Longitude,
+	}, true
+}
+
+type kmlFile struct {
+	XMLName  xml.Name    `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Document kmlDocument `xml:"Document"`
+}
+
+type kmlDocument struct {
+	Name    string      `xml:"name"`
+	Folders []kmlFolder `xml:"Folder"`
+}
+
+type kmlFolder struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string   `xml:"name"`
+	Description cdata    `xml:"description"`
+	Point       kmlPoint `xml:"Point"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type cdata struct {
+	Text string `xml:",cdata"`
+}
+
+// Write writes the document as KML.
+func (d *Document) Write(w io.Writer) error {
+	file := kmlFile{Document: kmlDocument{Name: d.Name}}
+	for _, folder := range d.Folders {
+		f := kmlFolder{Name: folder.Name}
+		for _, p := range folder.Placemarks {
+			f.Placemarks = append(f.Placemarks, kmlPlacemark{
+				Name:        p.Name,
+				Description: cdata{Text: p.Description},
+				// KML coordinates are longitude first
+				Point: kmlPoint{Coordinates: fmt.Sprintf("%f,%f", p.Longitude, p.Latitude)},
+			})
+		}
+		file.Document.Folders = append(file.Document.Folders, f)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("error writing KML: %v", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(file); err != nil {
+		return fmt.Errorf("error writing KML: %v", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("error writing KML: %v", err)
+	}
+	return nil
+}
+
+// WriteKMZ writes the document as KMZ: a zip archive holding it as doc.kml.
+func (d *Document) WriteKMZ(w io.Writer) error {
+	archive := zip.NewWriter(w)
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: "doc.kml", Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return fmt.Errorf("error writing KMZ: %v", err)
+	}
+	if err := d.Write(file); err != nil {
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("error writing KMZ: %v", err)
+	}
+	return nil
+}
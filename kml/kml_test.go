@@ -0,0 +1,139 @@
+package kml_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/tonyjhuang/reddit-to-gmap/kml"
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+var restaurants = []maps.Restaurant{
+	{
+		Name:      "Joe's",
+		Upvotes:   515,
+		RedditUrl: "https://www.reddit.com/r/foodnyc/comments/a1/joes/",
+		GoogleMapsData: maps.GoogleMapsData{
+			Name:            "Joe's Pizza",
+			Type:            "Pizza & <Slices>",
+			Rating:          4.5,
+			UserRatingCount: 2271,
+			GoogleMapsUrl:   `https://www.google.com/maps/search/?api=1&query="Joe's"`,
+			Latitude:        40.7306,
+			Longitude:       -73.989,
+		},
+	},
+	// No coordinates
+	{Name: "Somewhere", Upvotes: 300},
+	// No Places name
+	{
+		Name:           "Duzan",
+		Upvotes:        120,
+		GoogleMapsData: maps.GoogleMapsData{Latitude: 40.767, Longitude: -73.921},
+	},
+}
+
+func TestRestaurants(t *testing.T) {
+	doc := kml.Restaurants("r/foodnyc", restaurants)
+	if len(doc.Folders) != 1 {
+		t.Fatalf("got %d folders, want 1", len(doc.Folders))
+	}
+	placemarks := doc.Folders[0].Placemarks
+	if len(placemarks) != 2 {
+		t.Fatalf("got %d placemarks, want 2 (the one without coordinates is left out)", len(placemarks))
+	}
+
+	joes := placemarks[0]
+	if joes.Name != "Joe's Pizza" || joes.Latitude != 40.7306 || joes.Longitude != -73.989 {
+		t.Errorf("placemark = %+v", joes)
+	}
+	for _, want := range []string{
+		"#1 · Pizza &amp; &lt;Slices&gt;",
+		"Rating: 4.5 (2271 reviews)",
+		"Upvotes: 515",
+		`<a href="https://www.google.com/maps/search/?api=1&amp;query=&#34;Joe&#39;s&#34;">Google Maps</a>`,
+		`<a href="https://www.reddit.com/r/foodnyc/comments/a1/joes/">Reddit post</a>`,
+	} {
+		if !strings.Contains(joes.Description, want) {
+			t.Errorf("description = %q, want it to contain %q", joes.Description, want)
+		}
+	}
+
+	// The rank counts the restaurant left out, and the name falls back to
+	// the one from Reddit
+	duzan := placemarks[1]
+	if duzan.Name != "Duzan" || !strings.HasPrefix(duzan.Description, "#3<br>") {
+		t.Errorf("placemark = %+v, want Duzan ranked #3", duzan)
+	}
+}
+
+// parsedKML is the part of a KML document the tests check.
+type parsedKML struct {
+	Name       string `xml:"Document>name"`
+	Placemarks []struct {
+		Name        string `xml:"name"`
+		Description string `xml:"description"`
+		Coordinates string `xml:"Point>coordinates"`
+	} `xml:"Document>Folder>Placemark"`
+}
+
+func parse(t *testing.T, data []byte) parsedKML {
+	t.Helper()
+	var doc parsedKML
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid KML: %v\n%s", err, data)
+	}
+	return doc
+}
+
+func TestWrite(t *testing.T) {
+	var b bytes.Buffer
+	if err := kml.Restaurants("r/foodnyc", restaurants).Write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := parse(t, b.Bytes())
+	if doc.Name != "r/foodnyc" || len(doc.Placemarks) != 2 {
+		t.Fatalf("document = %+v", doc)
+	}
+	p := doc.Placemarks[0]
+	// Coordinates are longitude first
+	if p.Name != "Joe's Pizza" || p.Coordinates != "-73.989000,40.730600" {
+		t.Errorf("placemark = %+v", p)
+	}
+	want := kml.Restaurants("r/foodnyc", restaurants).Folders[0].Placemarks[0].Description
+	if p.Description != want {
+		t.Errorf("description = %q, want %q", p.Description, want)
+	}
+}
+
+func TestWriteKMZ(t *testing.T) {
+	var b bytes.Buffer
+	if err := kml.Restaurants("r/foodnyc", restaurants).WriteKMZ(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.File) != 1 || archive.File[0].Name != "doc.kml" {
+		t.Fatalf("archive holds %v, want just doc.kml", archive.File)
+	}
+	f, err := archive.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc := parse(t, data); len(doc.Placemarks) != 2 {
+		t.Errorf("doc.kml has %d placemarks, want 2", len(doc.Placemarks))
+	}
+}
@@ -2,83 +2,96 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
-	"reflect"
-	"sort"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
-	"github.com/tonyjhuang/reddit-to-gmap/cache"
+	"github.com/spf13/pflag"
+	"github.com/tonyjhuang/reddit-to-gmap/config"
 	"github.com/tonyjhuang/reddit-to-gmap/csv"
-	"github.com/tonyjhuang/reddit-to-gmap/gemini"
-	"github.com/tonyjhuang/reddit-to-gmap/maps"
+	"github.com/tonyjhuang/reddit-to-gmap/job"
+	"github.com/tonyjhuang/reddit-to-gmap/output"
 	"github.com/tonyjhuang/reddit-to-gmap/reddit"
+	"github.com/tonyjhuang/reddit-to-gmap/report"
+	"github.com/tonyjhuang/reddit-to-gmap/run"
 )
 
 var (
-	subreddit     string
-	numPosts      int
-	useCache      bool
-	timeRange     string
-	mapsQueryHint string
-	numOutput     int
+	configPath string
+	jobName    string
+	logFormat  string
+	logLevel   string
+	// jobOptions holds the settings of the pipeline command being run, bound
+	// to its flags. The pipeline only sees the copy passed to job.Run.
+	jobOptions job.Options
+	sinceFlag  string
+	untilFlag  string
+	monthFlag  string
+	// excludeRemovedFlag backs --exclude-removed, which jobOptions.Filter
+	// points to
+	excludeRemovedFlag bool
+	resumeID           string
+	reportPath         string
+	// currentRun records the progress of the pipeline command being run.
+	currentRun *run.State
 )
 
-type Config struct {
-	RedditClientID     string `env:"REDDIT_CLIENT_ID,required"`
-	RedditClientSecret string `env:"REDDIT_CLIENT_SECRET,required"`
-	GoogleMapsAPIKey   string `env:"GOOGLE_MAPS_API_KEY,required"`
-	GoogleGeminiAPIKey string `env:"GOOGLE_GEMINI_API_KEY,required"`
-}
-
-var cfg Config
-
 var rootCmd = &cobra.Command{
-	Use:   "reddit-to-gmap",
-	Short: "A CLI tool to export Reddit posts and generate Google Maps links",
-	Long:  `A CLI tool that allows you to export Reddit posts and generate Google Maps links from location data.`,
+	Use:               "reddit-to-gmap",
+	Short:             "A CLI tool to export Reddit posts and generate Google Maps links",
+	Long:              `A CLI tool that allows you to export Reddit posts and generate Google Maps links from location data.`,
+	PersistentPreRunE: prepareRoot,
 }
 
 var exportRedditCmd = &cobra.Command{
-	Use:   "debug:export-reddit",
-	Short: "Debug: Export top posts from a subreddit to a local cache",
+	Use:     "debug:export-reddit",
+	Short:   "Debug: Export top posts from a subreddit to a local cache",
+	PreRunE: preparePipeline,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		_, err := exportReddit(subreddit, numPosts, useCache)
-		return err
+		return runPipeline(cmd.Context(), job.PostsStage)
 	},
 }
 
 var exportRestaurantDataCmd = &cobra.Command{
-	Use:   "debug:export-restaurant-data",
-	Short: "Debug: Parse Reddit posts into structured restaurant data",
+	Use:     "debug:export-restaurant-data",
+	Short:   "Debug: Parse Reddit posts into structured restaurant data",
+	PreRunE: preparePipeline,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		_, err := exportRestaurantData(subreddit, numPosts, useCache)
-		return err
+		return runPipeline(cmd.Context(), job.RestaurantsStage)
 	},
 }
 
 var exportFullRestaurantDataCmd = &cobra.Command{
-	Use:   "debug:export-full-restaurant-data",
-	Short: "Debug: Pull canonical restaurant data from Google Maps API",
+	Use:     "debug:export-full-restaurant-data",
+	Short:   "Debug: Pull canonical restaurant data from Google Maps API",
+	PreRunE: preparePipeline,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		_, err := exportFullRestaurantData(subreddit, numPosts, useCache)
-		return err
+		return runPipeline(cmd.Context(), job.FullRestaurantsStage)
 	},
 }
 
 var generateTopPostGoogleMapCSVCmd = &cobra.Command{
-	Use:   "generate-top-post-google-map-csv",
-	Short: "Generate a CSV file from top Reddit posts for importing into a custom Google Map",
+	Use:     "generate-top-post-google-map-csv",
+	Short:   "Generate a CSV file from top Reddit posts for importing into a custom Google Map",
+	PreRunE: preparePipeline,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return exportToCSV(subreddit, numPosts, useCache)
+		return runPipeline(cmd.Context(), "")
 	},
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", config.DefaultPath, "Path to the job configuration file")
+	rootCmd.PersistentFlags().StringVar(&jobName, "job", "", "Name of a job in the configuration file to take settings from; explicit flags take precedence")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log format (text, json)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Minimum log level (debug, info, warn, error)")
+
 	rootCmd.AddCommand(exportRedditCmd)
 	rootCmd.AddCommand(exportRestaurantDataCmd)
 	rootCmd.AddCommand(exportFullRestaurantDataCmd)
@@ -86,271 +99,273 @@ func init() {
 
 	// Add flags to all commands
 	for _, cmd := range []*cobra.Command{exportRedditCmd, exportRestaurantDataCmd, exportFullRestaurantDataCmd, generateTopPostGoogleMapCSVCmd} {
-		cmd.Flags().StringVarP(&subreddit, "subreddit", "s", "", "Subreddit to fetch posts from (required)")
-		cmd.Flags().IntVarP(&numPosts, "num-posts", "n", 10, "Number of posts to fetch")
-		cmd.Flags().StringVarP(&timeRange, "time-range", "t", "month", "Time range for posts (hour, day, week, month, year, all)")
-		cmd.Flags().StringVarP(&mapsQueryHint, "maps-query-hint", "l", "", "Location hint for Google Maps queries (e.g. 'NYC', 'San Francisco')")
+		cmd.Flags().StringVarP(&jobOptions.Subreddit, "subreddit", "s", "", "Subreddit to fetch posts from (required)")
+		cmd.Flags().IntVarP(&jobOptions.NumPosts, "num-posts", "n", 10, "Number of posts to fetch")
+		cmd.Flags().StringVarP(&jobOptions.Listing.TimeRange, "time-range", "t", "month", "Time range for posts (hour, day, week, month, year, all)")
+		cmd.Flags().StringVarP(&jobOptions.MapsQueryHint, "maps-query-hint", "l", "", "Location hint for Google Maps queries (e.g. 'NYC', 'San Francisco')")
+		cmd.Flags().StringVar(&jobOptions.Listing.Sort, "listing", reddit.ListingTop, "Listing to fetch posts from ("+strings.Join(reddit.ListingSorts, ", ")+")")
+		cmd.Flags().StringVarP(&jobOptions.Listing.Query, "query", "q", "", "Search query, restricted to the subreddit (requires --listing search)")
+		cmd.Flags().StringVar(&jobOptions.Listing.SearchSort, "search-sort", "", "Sort order for search results (relevance, hot, top, new, comments)")
+		cmd.Flags().StringVar(&sinceFlag, "since", "", "Fetch posts submitted on or after this date (YYYY-MM-DD, UTC) instead of using a listing")
+		cmd.Flags().StringVar(&untilFlag, "until", "", "With --since, fetch posts submitted before this date (YYYY-MM-DD, UTC; default: now)")
+		cmd.Flags().StringVar(&monthFlag, "month", "", "Fetch posts submitted in a calendar month (YYYY-MM, or 'last' for the previous month); shorthand for --since/--until")
 		cmd.MarkFlagRequired("subreddit")
 	}
 
-	// Add use-cache flag to export commands
-	for _, cmd := range []*cobra.Command{exportRedditCmd, exportRestaurantDataCmd, exportFullRestaurantDataCmd, generateTopPostGoogleMapCSVCmd} {
-		cmd.Flags().BoolVar(&useCache, "use-cache", true, "Whether to use cached data if available")
+	// Add post filter flags to commands that send posts to Gemini
+	for _, cmd := range []*cobra.Command{exportRestaurantDataCmd, exportFullRestaurantDataCmd, generateTopPostGoogleMapCSVCmd, ingestDumpCmd} {
+		cmd.Flags().IntVar(&jobOptions.Filter.MinScore, "min-score", 0, "Drop posts with fewer upvotes than this")
+		cmd.Flags().IntVar(&jobOptions.Filter.MinComments, "min-comments", 0, "Drop posts with fewer comments than this")
+		cmd.Flags().Float64Var(&jobOptions.Filter.MinUpvoteRatio, "min-upvote-ratio", 0, "Drop posts with a lower upvote ratio than this (0-1)")
+		cmd.Flags().StringSliceVar(&jobOptions.Filter.FlairAllow, "flair-allow", nil, "Only keep posts with one of these flairs")
+		cmd.Flags().StringSliceVar(&jobOptions.Filter.FlairDeny, "flair-deny", nil, "Drop posts with any of these flairs")
+		cmd.Flags().StringVar(&jobOptions.Filter.TitleMatch, "title-match", "", "Only keep posts whose title matches this regular expression")
+		cmd.Flags().StringVar(&jobOptions.Filter.TitleExclude, "title-exclude", "", "Drop posts whose title matches this regular expression")
+		cmd.Flags().IntVar(&jobOptions.Filter.MinSelftextLength, "min-selftext-length", 0, "Drop posts whose body is shorter than this many characters")
+		cmd.Flags().BoolVar(&excludeRemovedFlag, "exclude-removed", true, "Drop removed and deleted posts")
+		cmd.Flags().BoolVar(&jobOptions.Filter.ExcludeNSFW, "exclude-nsfw", false, "Drop posts marked NSFW")
 	}
+	jobOptions.Filter.ExcludeRemoved = &excludeRemovedFlag
 
-	// Add num-output flag to CSV generation command
-	generateTopPostGoogleMapCSVCmd.Flags().IntVarP(&numOutput, "num-output", "o", 0, "Maximum number of rows to write to the CSV (0 means no limit)")
-}
-
-func main() {
-	var err error
-	if err = godotenv.Load(); err != nil {
-		fmt.Printf("Warning: Could not load .env file: %v (this is OK if environment variables are set directly)\n", err)
-	}
-	cfg, err = env.ParseAs[Config]()
-	if err != nil {
-		fmt.Printf("Error parsing environment variables: %+v\n", err)
-		os.Exit(1)
+	// Add use-cache and resume flags to export commands
+	for _, cmd := range []*cobra.Command{exportRedditCmd, exportRestaurantDataCmd, exportFullRestaurantDataCmd, generateTopPostGoogleMapCSVCmd, ingestDumpCmd} {
+		cmd.Flags().BoolVar(&jobOptions.UseCache, "use-cache", true, "Whether to use cached data if available")
+		cmd.Flags().StringVar(&resumeID, "resume", "", "ID of an interrupted or failed run to resume with its original settings")
+		cmd.Flags().StringVar(&reportPath, "report", "", "Also write the run report to this file (it is always kept with the run state)")
 	}
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	// Add output flags to commands that write output files
+	for _, cmd := range []*cobra.Command{generateTopPostGoogleMapCSVCmd, ingestDumpCmd} {
+		cmd.Flags().IntVarP(&jobOptions.NumOutput, "num-output", "o", 0, "Maximum number of rows to write to the CSV (0 means no limit)")
+		cmd.Flags().StringVar(&jobOptions.OutputDir, "output-dir", output.DefaultDir, "Directory to write output files to, or - to stream to stdout")
+		cmd.Flags().StringVar(&jobOptions.Filename, "filename", output.DefaultFilenameTemplate, "Output file name template; supports {subreddit}, {date}, {time_range}, {job} and {format}")
+		cmd.Flags().StringSliceVarP(&jobOptions.Formats, "format", "f", []string{"csv"}, "Output formats to write ("+strings.Join(job.OutputFormats, ", ")+")")
+		cmd.Flags().StringVar(&jobOptions.CSVSchema, "csv-schema", string(csv.SchemaLegacy), "CSV column layout (legacy for Google My Maps import, v2 for one value per column)")
+		cmd.Flags().StringSliceVar(&jobOptions.Boundaries, "boundaries", nil, "GeoJSON files of neighborhood boundaries to assign restaurants a neighborhood and borough from")
+		cmd.Flags().StringSliceVar(&jobOptions.Neighborhoods, "neighborhood", nil, "Only output restaurants in these neighborhoods")
+		cmd.Flags().StringSliceVar(&jobOptions.Boroughs, "borough", nil, "Only output restaurants in these boroughs")
+		cmd.Flags().StringVar(&jobOptions.GroupBy, "group-by", "neighborhood", "Group the Markdown output by ("+strings.Join(job.GroupByValues, ", ")+")")
+		cmd.Flags().IntVar(&jobOptions.FeedRuns, "feed-runs", job.DefaultFeedRuns, "Number of runs the Atom feed keeps entries for")
+		cmd.Flags().StringSliceVar(&jobOptions.Stages, "stages", job.DefaultStages, "Pipeline stages to run, in order")
 	}
 }
 
-// getCachedOrFetch is a generic helper function that handles caching logic for any type T
-func getCachedOrFetch[T any](cacheKey string, useCache bool, fetchFn func() (T, error)) (T, error) {
-	var result T
+// preparePipeline runs before commands that fetch and process posts. It
+// starts a new run, or reloads the run given with --resume and its settings.
+func preparePipeline(cmd *cobra.Command, args []string) error {
+	credentials, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+	jobOptions.Credentials = credentials
 
-	// Check cache first if enabled
-	if useCache && cache.CacheExists(cacheKey) {
-		cacheData, err := cache.ReadFromCache(cacheKey)
-		if err != nil {
-			return result, fmt.Errorf("error reading from cache: %v", err)
+	if resumeID == "" {
+		if err := setWindow(time.Now()); err != nil {
+			return err
 		}
-
-		// Convert cached data back to type T using JSON marshaling/unmarshaling
-		jsonData, err := json.Marshal(cacheData.Data)
+		id, err := run.NewID(run.DefaultDir, jobOptions.Subreddit, time.Now())
 		if err != nil {
-			return result, fmt.Errorf("error marshaling cache data: %v", err)
+			return err
 		}
-
-		if err := json.Unmarshal(jsonData, &result); err != nil {
-			return result, fmt.Errorf("error unmarshaling cache data: %v", err)
-		}
-
-		fmt.Printf("Found %d items in cache for %s\n", reflect.ValueOf(result).Len(), cacheKey)
-		return result, nil
+		currentRun = run.New(run.DefaultDir, id, cmd.Name(), runFlags(cmd))
+		jobOptions.State = currentRun
+		jobOptions.Report = report.New(currentRun.ID, cmd.Name())
+		slog.Info("Starting run", "run_id", currentRun.ID, "command", cmd.Name())
+		return currentRun.Save()
 	}
 
-	// Fetch fresh data
-	result, err := fetchFn()
+	state, err := run.Load(run.DefaultDir, resumeID)
 	if err != nil {
-		return result, err
+		return err
 	}
-
-	// Cache the result
-	if err := cache.WriteToCache(cacheKey, result); err != nil {
-		return result, fmt.Errorf("error writing to cache: %v", err)
+	if state.Command != cmd.Name() {
+		return fmt.Errorf("run %s was started by %s, not %s", state.ID, state.Command, cmd.Name())
+	}
+	if state.Status == run.StatusCompleted {
+		return fmt.Errorf("run %s has already completed", state.ID)
+	}
+	if err := applyFlags(cmd, state.Flags, "run "+state.ID); err != nil {
+		return err
+	}
+	// Load the CSV columns of the job the run was started with
+	if err := applyJob(cmd, args); err != nil {
+		return err
+	}
+	if err := setWindow(time.Now()); err != nil {
+		return err
 	}
 
-	return result, nil
-}
-
-// exportReddit fetches Reddit posts and caches them. Returns the fetched posts.
-func exportReddit(subreddit string, numPosts int, useCache bool) ([]reddit.Post, error) {
-	return getCachedOrFetch(
-		subreddit,
-		useCache,
-		func() ([]reddit.Post, error) {
-			client := reddit.NewClient(cfg.RedditClientID, cfg.RedditClientSecret)
-			posts, err := client.GetPosts(subreddit, numPosts, timeRange)
-			if err != nil {
-				return nil, fmt.Errorf("error fetching posts: %v", err)
-			}
-			fmt.Printf("Successfully exported %d posts from r/%s (time range: %s)\n", len(posts), subreddit, timeRange)
-			return posts, nil
-		},
-	)
+	currentRun = state
+	currentRun.Status = run.StatusRunning
+	jobOptions.State = currentRun
+	jobOptions.Report = report.New(currentRun.ID, cmd.Name())
+	slog.Info("Resuming run", "run_id", currentRun.ID, "command", cmd.Name())
+	return currentRun.Save()
 }
 
-// exportRestaurantData processes Reddit posts into restaurant data and caches the results.
-// Returns the processed restaurant data.
-func exportRestaurantData(subreddit string, numPosts int, useCache bool) ([]gemini.Restaurant, error) {
-	restaurantCacheKey := subreddit + "_restaurants"
-	return getCachedOrFetch(
-		restaurantCacheKey,
-		useCache,
-		func() ([]gemini.Restaurant, error) {
-			fmt.Printf("parsing reddit data with gemini...\n")
-			// Get Reddit posts using exportReddit
-			posts, err := exportReddit(subreddit, numPosts, useCache)
-			if err != nil {
-				return nil, err
-			}
-
-			// Create a Gemini client
-			ctx := context.Background()
-			geminiClient, err := gemini.NewClient(ctx, cfg.GoogleGeminiAPIKey)
-			if err != nil {
-				return nil, fmt.Errorf("error creating Gemini client: %v", err)
-			}
-			defer geminiClient.Close()
-
-			// Process posts in chunks of 100
-			const chunkSize = 100
-			var allRestaurants []gemini.Restaurant
-
-			for i := 0; i < len(posts); i += chunkSize {
-				end := i + chunkSize
-				if end > len(posts) {
-					end = len(posts)
-				}
-				chunk := posts[i:end]
-
-				// Process the chunk with Gemini
-				restaurantData, err := geminiClient.ToRestaurantData(ctx, chunk)
-				if err != nil {
-					return nil, fmt.Errorf("error processing posts chunk with Gemini: %v", err)
-				}
-
-				allRestaurants = append(allRestaurants, restaurantData...)
-				fmt.Printf("Processed chunk %d/%d posts\n", end, len(posts))
-			}
-
-			// Sort all restaurants by upvotes in descending order
-			sort.Slice(allRestaurants, func(i, j int) bool {
-				return allRestaurants[i].Upvotes > allRestaurants[j].Upvotes
-			})
-
-			var uniqueRestaurants = dedupeRestaurants(allRestaurants)
-
-			fmt.Printf("Successfully exported %d restaurants from r/%s\n", len(uniqueRestaurants), subreddit)
-			return uniqueRestaurants, nil
-		},
-	)
+// runFlags returns the flags set for a run, so that resuming it uses the
+// same settings. Relative dates are pinned to the window they resolved to.
+func runFlags(cmd *cobra.Command) map[string]string {
+	flags := make(map[string]string)
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if flag.Name == "resume" {
+			return
+		}
+		if value, ok := flag.Value.(pflag.SliceValue); ok {
+			flags[flag.Name] = strings.Join(value.GetSlice(), ",")
+		} else {
+			flags[flag.Name] = flag.Value.String()
+		}
+	})
+	if monthFlag != "" {
+		flags["month"] = jobOptions.Since.Format("2006-01")
+	}
+	if sinceFlag != "" && untilFlag == "" {
+		flags["until"] = jobOptions.Until.Format(time.DateOnly)
+	}
+	return flags
 }
 
-// dedupeRestaurants removes duplicate Restaurant entries based on the Name field.
-// It preserves the order of the first occurrence of each unique restaurant.
-// It returns a new slice containing only the unique restaurants.
-func dedupeRestaurants(restaurants []gemini.Restaurant) []gemini.Restaurant {
-	seen := make(map[string]struct{})
-
-	// Initialize a new slice to store the unique restaurants.
-	uniqueRestaurants := make([]gemini.Restaurant, 0, len(restaurants)/2) // Example capacity
-
-	for _, r := range restaurants {
-		// Check if we've already seen a restaurant with this name
-		if _, found := seen[r.Name]; !found {
-			// If this name hasn't been seen before:
-			// 1. Mark it as seen by adding it to the map.
-			seen[r.Name] = struct{}{}
-			// 2. Append the current restaurant to our result slice.
-			uniqueRestaurants = append(uniqueRestaurants, r)
+// finishRun writes the run's report to --report and, unless the run
+// completed, logs how to resume it. It returns the run's error.
+func finishRun(result job.Result, err error) error {
+	if currentRun == nil {
+		return err
+	}
+	if reportPath != "" {
+		if writeErr := result.Report.WriteFile(reportPath); writeErr != nil {
+			slog.Warn("Could not write run report", "error", writeErr)
 		}
-		// If the name was already 'found' in the 'seen' map, we simply skip
-		// this element, effectively dropping the duplicate.
 	}
-
-	return uniqueRestaurants
+	slog.Info("Run finished",
+		"run_id", currentRun.ID,
+		"status", result.Status,
+		"duration_seconds", result.Report.DurationSeconds,
+		"estimated_cost_usd", result.Report.EstimatedCostUSD)
+	if result.Status != run.StatusCompleted {
+		slog.Info("Resume the run with --resume", "run_id", currentRun.ID)
+	}
+	return err
 }
 
-// exportFullRestaurantData processes Reddit posts into restaurant data with canonicalized Google Maps links.
-// Returns the processed restaurant data.
-func exportFullRestaurantData(subreddit string, numPosts int, useCache bool) ([]maps.Restaurant, error) {
-	fullRestaurantCacheKey := subreddit + "_full_restaurants"
-	return getCachedOrFetch(
-		fullRestaurantCacheKey,
-		useCache,
-		func() ([]maps.Restaurant, error) {
-			// Get restaurant data using exportRestaurantData
-			restaurantData, err := exportRestaurantData(subreddit, numPosts, useCache)
-			if err != nil {
-				return nil, err
-			}
-
-			// Create a Maps client for place ID lookups
-			ctx := context.Background()
-			mapsClient, err := maps.NewClient(ctx, cfg.GoogleMapsAPIKey)
-			if err != nil {
-				return nil, fmt.Errorf("error creating Maps client: %v", err)
-			}
-			defer mapsClient.Close()
-
-			// Process each restaurant to add/canonicalize Google Maps links
-			var fullRestaurants []maps.Restaurant
-			for _, restaurant := range restaurantData {
-				result, err := mapsClient.FetchGoogleMapsLink(ctx, &restaurant, mapsQueryHint)
-				if err != nil {
-					fmt.Printf("Warning: error fetching Maps link for %s: %v\n", restaurant.Name, err)
-					continue
-				}
-				if result != nil {
-					fullRestaurants = append(fullRestaurants, *result)
-				}
-				// Add 2 second delay between API calls
-				time.Sleep(2 * time.Second)
-			}
-
-			fmt.Printf("Successfully exported %d restaurants with Maps data from r/%s\n", len(fullRestaurants), subreddit)
-			return fullRestaurants, nil
-		},
-	)
+// setWindow sets the date window of the job options from the --since,
+// --until and --month flags.
+func setWindow(now time.Time) error {
+	var err error
+	jobOptions.Since, jobOptions.Until, err = job.ParseWindow(sinceFlag, untilFlag, monthFlag, now)
+	return err
 }
 
-// exportToCSV exports restaurant data to a CSV file
-func exportToCSV(subreddit string, numPosts int, useCache bool) error {
-	// Get the full restaurant data
-	restaurants, err := exportFullRestaurantData(subreddit, numPosts, useCache)
+// loadCredentials parses API credentials from the environment. It runs
+// before commands that call Reddit or Google APIs; offline commands skip it.
+func loadCredentials() (job.Credentials, error) {
+	if err := godotenv.Load(); err != nil {
+		slog.Debug("Could not load .env file; using the environment", "error", err)
+	}
+
+	credentials, err := env.ParseAs[job.Credentials]()
 	if err != nil {
-		return fmt.Errorf("error getting restaurant data: %v", err)
+		return credentials, fmt.Errorf("error parsing environment variables: %+v", err)
 	}
+	return credentials, nil
+}
 
-	// Sort restaurants by upvotes in descending order
-	sort.Slice(restaurants, func(i, j int) bool {
-		return restaurants[i].Upvotes > restaurants[j].Upvotes
-	})
+// prepareRoot runs before every command. It sets up logging and applies the
+// job selected with --job.
+func prepareRoot(cmd *cobra.Command, args []string) error {
+	if err := setupLogging(); err != nil {
+		return err
+	}
+	return applyJob(cmd, args)
+}
 
-	// Apply numOutput limit if specified
-	if numOutput > 0 && len(restaurants) > numOutput {
-		restaurants = restaurants[:numOutput]
+// setupLogging configures the default logger from --log-format and
+// --log-level. Logs go to stderr so they never mix with output streamed to
+// stdout.
+func setupLogging() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return fmt.Errorf("invalid --log-level %q (expected debug, info, warn or error)", logLevel)
+	}
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch logFormat {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	default:
+		return fmt.Errorf("invalid --log-format %q (expected text or json)", logFormat)
 	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
 
-	// Create CSV filename with date and time range
-	currentDate := time.Now().Format("20060102")
-	filename := fmt.Sprintf("%s_%s_%s.csv", subreddit, currentDate, timeRange)
+// applyJob fills in flags that were not set on the command line from the job
+// selected with --job, and loads the job's CSV columns and notifications.
+func applyJob(cmd *cobra.Command, args []string) error {
+	if jobName == "" {
+		return nil
+	}
 
-	// Create CSV writer
-	writer, err := csv.NewWriter(filename)
+	file, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	selected, err := file.Job(jobName)
 	if err != nil {
-		return fmt.Errorf("error creating CSV writer: %v", err)
+		return err
 	}
-	defer writer.Close()
 
-	// Write header
-	header := []string{"Name", "Type", "Google Maps url", "Google Maps rating", "Reddit url", "Lat", "Lng"}
-	if err := writer.WriteHeader(header); err != nil {
-		return fmt.Errorf("error writing CSV header: %v", err)
+	if err := applyFlags(cmd, selected.Flags(), fmt.Sprintf("job %q", selected.Name)); err != nil {
+		return err
+	}
+	sinks, err := selected.Notify.Sinks()
+	if err != nil {
+		return fmt.Errorf("invalid job %q: %v", selected.Name, err)
 	}
+	jobOptions.Name = selected.Name
+	jobOptions.CSVColumns = selected.CSV.Columns
+	jobOptions.Notify = sinks
+	jobOptions.NotifyTop = selected.Notify.Top
+	return nil
+}
 
-	// Write data rows
-	for i, restaurant := range restaurants {
-		row := []string{
-			fmt.Sprintf("%s (#%d, %d upvotes)", restaurant.GoogleMapsData.Name, i+1, restaurant.Upvotes),
-			restaurant.GoogleMapsData.Type,
-			restaurant.GoogleMapsData.GoogleMapsUrl,
-			fmt.Sprintf("%.1f (%d reviews)", restaurant.GoogleMapsData.Rating, restaurant.GoogleMapsData.UserRatingCount),
-			restaurant.RedditUrl,
-			fmt.Sprintf("%.6f", restaurant.GoogleMapsData.Latitude),
-			fmt.Sprintf("%.6f", restaurant.GoogleMapsData.Longitude),
+// applyFlags sets the given flags that were not set on the command line.
+// source names where the values came from, for error messages.
+func applyFlags(cmd *cobra.Command, flags map[string]string, source string) error {
+	for name, value := range flags {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || flag.Changed {
+			continue
 		}
-		if err := writer.WriteRow(row); err != nil {
-			return fmt.Errorf("error writing CSV row: %v", err)
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s in %s: %v", name, source, err)
 		}
 	}
-
-	fmt.Printf("Successfully exported %d restaurants to %s\n", len(restaurants), writer.Path())
 	return nil
 }
+
+// runPipeline runs the pipeline with the command's settings. If through is
+// set, the default stages up to and including it are run instead of
+// --stages, for the debug commands.
+func runPipeline(ctx context.Context, through string) error {
+	opts := jobOptions
+	if through != "" {
+		opts.Stages = job.StagesThrough(through)
+	}
+	return finishRun(job.Run(ctx, opts))
+}
+
+func main() {
+	// Ctrl-C or SIGTERM cancels the run; stages save what they have finished
+	// so a rerun picks up from there.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -3,7 +3,10 @@ package maps
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"net/url"
 	"strings"
+	"time"
 
 	places "cloud.google.com/go/maps/places/apiv1"
 	placespb "cloud.google.com/go/maps/places/apiv1/placespb"
@@ -26,12 +29,31 @@ type Restaurant struct {
 	Name           string         `json:"name"`
 	Upvotes        int            `json:"upvotes"`
 	RedditUrl      string         `json:"reddit_url"`
+	PostID         string         `json:"post_id,omitempty"`
+	PostTitle      string         `json:"post_title,omitempty"`
+	PostFlair      string         `json:"post_flair,omitempty"`
+	PostedAt       time.Time      `json:"posted_at,omitzero"`
+	PhotoURLs      []string       `json:"photo_urls,omitempty"`
 	Neighborhood   string         `json:"neighborhood,omitempty"`
+	Borough        string         `json:"borough,omitempty"` // Set from boundary polygons; see the geo package
+	Dishes         []string       `json:"dishes,omitempty"`
+	Mentions       int            `json:"mentions,omitempty"` // Number of posts about this restaurant
 	GoogleMapsData GoogleMapsData `json:"google_maps_data"`
 }
 
 type Client struct {
-	client *places.Client
+	client   *places.Client
+	requests int
+}
+
+// PlaceID returns the Google place ID embedded in GoogleMapsUrl, or an empty
+// string if the URL does not carry one.
+func (d GoogleMapsData) PlaceID() string {
+	u, err := url.Parse(d.GoogleMapsUrl)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("query_place_id")
 }
 
 func NewClient(ctx context.Context, apiKey string) (*Client, error) {
@@ -53,11 +75,16 @@ func (c *Client) Close() {
 	c.client.Close()
 }
 
+// Requests returns the number of Text Search requests the client has made.
+func (c *Client) Requests() int {
+	return c.requests
+}
+
 // FetchGoogleMapsLink processes a restaurant to either canonicalize its existing Google Maps link
 // or search for a new one if none exists. For searches, it uses the restaurant name and neighborhood
 // (if available) to find the most relevant match.
 func (c *Client) FetchGoogleMapsLink(ctx context.Context, restaurant *gemini.Restaurant, locationHint string) (*Restaurant, error) {
-	fmt.Printf("Fetching Google Maps data for %s\n", restaurant.Name)
+	slog.Debug("Fetching Google Maps data", "restaurant", restaurant.Name)
 
 	// Build search query with restaurant name and location context
 	query := restaurant.Name
@@ -74,13 +101,14 @@ func (c *Client) FetchGoogleMapsLink(ctx context.Context, restaurant *gemini.Res
 	// Set the required field mask header for all Places API requests
 	ctx = callctx.SetHeaders(ctx, callctx.XGoogFieldMaskHeader, "*")
 
+	c.requests++
 	resp, err := c.client.SearchText(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search for place: %v", err)
 	}
 
 	if len(resp.Places) == 0 {
-		fmt.Printf("No results found for %s: %s\n", restaurant.Name, query)
+		slog.Warn("No Google Maps results", "restaurant", restaurant.Name, "query", query)
 		return nil, nil // No results found
 	}
 
@@ -89,15 +117,13 @@ func (c *Client) FetchGoogleMapsLink(ctx context.Context, restaurant *gemini.Res
 	placeID := strings.TrimPrefix(place.Name, "places/")
 
 	if place.UserRatingCount == nil {
-		fmt.Printf("No user rating count found for %s\n", restaurant.Name)
-		fmt.Printf("Place: %+v\n", place)
+		slog.Warn("Skipping place without a user rating count", "restaurant", restaurant.Name, "place_id", placeID, "place_name", place.GetDisplayName().GetText())
 		return nil, nil
 	}
 
 	var resturantType string
 	if place.PrimaryTypeDisplayName == nil {
-		fmt.Printf("No business type found for %s\n", restaurant.Name)
-		fmt.Printf("Place: %+v\n", place)
+		slog.Debug("Place has no business type", "restaurant", restaurant.Name, "place_id", placeID)
 		resturantType = ""
 	} else {
 		resturantType = place.PrimaryTypeDisplayName.Text
@@ -108,7 +134,14 @@ func (c *Client) FetchGoogleMapsLink(ctx context.Context, restaurant *gemini.Res
 		Name:         restaurant.Name,
 		Upvotes:      restaurant.Upvotes,
 		RedditUrl:    restaurant.RedditUrl,
+		PostID:       restaurant.PostID,
+		PostTitle:    restaurant.PostTitle,
+		PostFlair:    restaurant.PostFlair,
+		PostedAt:     restaurant.PostedAt,
+		PhotoURLs:    restaurant.PhotoURLs,
 		Neighborhood: restaurant.Neighborhood,
+		Dishes:       restaurant.Dishes,
+		Mentions:     restaurant.Mentions,
 		GoogleMapsData: GoogleMapsData{
 			Name:            place.DisplayName.Text,
 			Latitude:        place.Location.Latitude,
@@ -0,0 +1,166 @@
+// Package markdown writes ranked restaurants as a Markdown document, with a
+// table per group, for READMEs, wikis and chat.
+package markdown
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+// Document is a Markdown document of ranked restaurants.
+type Document struct {
+	Title string
+	// Parameters are listed under the title, e.g. the run's settings.
+	Parameters []Parameter
+	Groups     []Group
+}
+
+// Parameter is a named value listed under the title.
+type Parameter struct {
+	Name  string
+	Value string
+}
+
+// Group is a titled table of restaurants. A group without a name is written
+// without a heading. Summary and Links, if any, are written above the table.
+type Group struct {
+	Name    string
+	Summary string
+	Links   []Link
+	Rows    []Row
+}
+
+// Link is a link written above a group's table.
+type Link struct {
+	Text string
+	URL  string
+}
+
+// Row is a restaurant and its overall rank.
+type Row struct {
+	Rank       int
+	Restaurant maps.Restaurant
+}
+
+// Restaurants returns a document with the restaurants grouped by the key
+// groupBy returns. Groups are in the order of their best ranked restaurant;
+// restaurants with an empty key go in an "Other" group at the end. If
+// groupBy is nil, there is a single table.
+func Restaurants(title string, parameters []Parameter, restaurants []maps.Restaurant, groupBy func(maps.Restaurant) string) *Document {
+	doc := &Document{Title: title, Parameters: parameters}
+	if groupBy == nil {
+		group := Group{}
+		for i, r := range restaurants {
+			group.Rows = append(group.Rows, Row{Rank: i + 1, Restaurant: r})
+		}
+		doc.Groups = []Group{group}
+		return doc
+	}
+
+	index := make(map[string]int)
+	var other Group
+	for i, r := range restaurants {
+		row := Row{Rank: i + 1, Restaurant: r}
+		key := strings.TrimSpace(groupBy(r))
+		if key == "" {
+			other.Rows = append(other.Rows, row)
+			continue
+		}
+		j, ok := index[strings.ToLower(key)]
+		if !ok {
+			j = len(doc.Groups)
+			index[strings.ToLower(key)] = j
+			doc.Groups = append(doc.Groups, Group{Name: key})
+		}
+		doc.Groups[j].Rows = append(doc.Groups[j].Rows, row)
+	}
+	if len(other.Rows) > 0 {
+		if len(doc.Groups) > 0 {
+			other.Name = "Other"
+		}
+		doc.Groups = append(doc.Groups, other)
+	}
+	return doc
+}
+
+// Write writes the document as Markdown.
+func (d *Document) Write(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n", escape(d.Title))
+	if len(d.Parameters) > 0 {
+		b.WriteString("\n")
+		for _, p := range d.Parameters {
+			fmt.Fprintf(&b, "- **%s:** %s\n", escape(p.Name), escape(p.Value))
+		}
+	}
+
+	for _, g := range d.Groups {
+		if g.Name != "" {
+			fmt.Fprintf(&b, "\n## %s\n", escape(g.Name))
+		}
+		if g.Summary != "" {
+			fmt.Fprintf(&b, "\n%s\n", escape(g.Summary))
+		}
+		if len(g.Links) > 0 {
+			links := make([]string, len(g.Links))
+			for i, l := range g.Links {
+				links[i] = link(l.Text, l.URL)
+			}
+			fmt.Fprintf(&b, "\n%s\n", strings.Join(links, " · "))
+		}
+		b.WriteString("\n| Rank | Restaurant | Type | Rating | Upvotes | Reddit |\n| --- | --- | --- | --- | --- | --- |\n")
+		for _, row := range g.Rows {
+			r := row.Restaurant
+			data := r.GoogleMapsData
+			fmt.Fprintf(&b, "| %d | %s | %s | %s | %d | %s |\n",
+				row.Rank, nameLink(r), escape(data.Type), rating(data), r.Upvotes, redditLink(r.RedditUrl))
+		}
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("error writing Markdown: %v", err)
+	}
+	return nil
+}
+
+// nameLink renders a restaurant's name as a link to its Google Maps page.
+func nameLink(r maps.Restaurant) string {
+	name := r.GoogleMapsData.Name
+	if name == "" {
+		name = r.Name
+	}
+	return link(name, r.GoogleMapsData.GoogleMapsUrl)
+}
+
+// redditLink renders a link to a Reddit post, or nothing if there is none.
+func redditLink(url string) string {
+	if url == "" {
+		return ""
+	}
+	return link("post", url)
+}
+
+// link renders a Markdown link, or just the text if there is no URL.
+func link(text, url string) string {
+	if url == "" {
+		return escape(text)
+	}
+	return fmt.Sprintf("[%s](%s)", escape(text), strings.NewReplacer("(", "%28", ")", "%29", " ", "%20").Replace(url))
+}
+
+// rating renders a Google Maps rating and its number of reviews.
+func rating(data maps.GoogleMapsData) string {
+	if data.UserRatingCount == 0 && data.Rating == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.1f (%d)", data.Rating, data.UserRatingCount)
+}
+
+// escape keeps text from breaking a table or being read as Markdown.
+func escape(s string) string {
+	return strings.NewReplacer("|", `\|`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,117 @@
+package markdown
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+func TestLink(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		url  string
+		want string
+	}{
+		{"plain", "Joe's Pizza", "https://maps.google.com/?cid=1", "[Joe's Pizza](https://maps.google.com/?cid=1)"},
+		{"no URL", "Joe's Pizza", "", "Joe's Pizza"},
+		{"pipe", "Bar | Grill", "", `Bar \| Grill`},
+		{"brackets", "[Closed] Joe's", "https://maps.google.com/?cid=1", `[\[Closed\] Joe's](https://maps.google.com/?cid=1)`},
+		{"emphasis", "*Best* _slice_", "", `\*Best\* \_slice\_`},
+		{"newline", "Joe's\nPizza", "", "Joe's Pizza"},
+		{
+			"URL with parentheses and spaces",
+			"Joe's",
+			"https://maps.google.com/?q=Joe's (Carmine St)",
+			"[Joe's](https://maps.google.com/?q=Joe's%20%28Carmine%20St%29)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := link(tt.text, tt.url); got != tt.want {
+				t.Errorf("link() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func restaurant(name, neighborhood string) maps.Restaurant {
+	return maps.Restaurant{Name: name, Neighborhood: neighborhood}
+}
+
+func TestRestaurantsGroups(t *testing.T) {
+	restaurants := []maps.Restaurant{
+		restaurant("A", "West Village"),
+		restaurant("B", ""),
+		restaurant("C", "Astoria"),
+		restaurant("D", "west village "),
+	}
+	byNeighborhood := func(r maps.Restaurant) string { return r.Neighborhood }
+
+	doc := Restaurants("r/foodnyc", nil, restaurants, byNeighborhood)
+	var groups []string
+	for _, g := range doc.Groups {
+		var ranks []string
+		for _, row := range g.Rows {
+			ranks = append(ranks, row.Restaurant.Name)
+		}
+		groups = append(groups, g.Name+": "+strings.Join(ranks, ","))
+	}
+	// Groups are in the order of their best ranked restaurant, match
+	// case-insensitively, and restaurants without one go last
+	if want := []string{"West Village: A,D", "Astoria: C", "Other: B"}; !slices.Equal(groups, want) {
+		t.Errorf("groups = %q, want %q", groups, want)
+	}
+	if rank := doc.Groups[0].Rows[1].Rank; rank != 4 {
+		t.Errorf("D rank = %d, want its overall rank 4", rank)
+	}
+
+	// Without any group keys, there is a single table without a heading
+	doc = Restaurants("r/foodnyc", nil, []maps.Restaurant{restaurant("A", "")}, byNeighborhood)
+	if len(doc.Groups) != 1 || doc.Groups[0].Name != "" {
+		t.Errorf("groups = %+v, want one unnamed group", doc.Groups)
+	}
+	doc = Restaurants("r/foodnyc", nil, restaurants, nil)
+	if len(doc.Groups) != 1 || len(doc.Groups[0].Rows) != 4 {
+		t.Errorf("groups = %+v, want one group of every restaurant", doc.Groups)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	restaurants := []maps.Restaurant{
+		{
+			Name:      "Joe's",
+			Upvotes:   515,
+			RedditUrl: "https://www.reddit.com/r/foodnyc/comments/a1/joes/",
+			GoogleMapsData: maps.GoogleMapsData{
+				Name:            "Joe's | Pizza",
+				Type:            "Pizza",
+				Rating:          4.5,
+				UserRatingCount: 2271,
+				GoogleMapsUrl:   "https://maps.google.com/?cid=1",
+			},
+		},
+		// No Places data
+		{Name: "Duzan", Upvotes: 120},
+	}
+	doc := Restaurants("Top restaurants on r/foodnyc", []Parameter{{Name: "Posts", Value: "100 posts"}}, restaurants, nil)
+
+	var b strings.Builder
+	if err := doc.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# Top restaurants on r/foodnyc
+
+- **Posts:** 100 posts
+
+| Rank | Restaurant | Type | Rating | Upvotes | Reddit |
+| --- | --- | --- | --- | --- | --- |
+| 1 | [Joe's \| Pizza](https://maps.google.com/?cid=1) | Pizza | 4.5 (2271) | 515 | [post](https://www.reddit.com/r/foodnyc/comments/a1/joes/) |
+| 2 | Duzan |  |  | 120 |  |
+`
+	if b.String() != want {
+		t.Errorf("Write() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
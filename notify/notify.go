@@ -0,0 +1,92 @@
+// Package notify tells people about finished runs. A Summary of a run, with
+// its top restaurants, the restaurants that are new since the run before it
+// and what failed, is sent to each configured Sink.
+package notify
+
+import (
+	"context"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/diff"
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+	"github.com/tonyjhuang/reddit-to-gmap/report"
+	"github.com/tonyjhuang/reddit-to-gmap/run"
+)
+
+// DefaultTop is the number of top restaurants in a summary when none is
+// given.
+const DefaultTop = 10
+
+// Sink delivers run summaries somewhere, such as a webhook.
+type Sink interface {
+	Send(ctx context.Context, summary *Summary) error
+}
+
+// Summary describes a finished run.
+type Summary struct {
+	RunID            string    `json:"run_id"`
+	Job              string    `json:"job,omitempty"`
+	Subreddit        string    `json:"subreddit"`
+	Status           string    `json:"status"`
+	Error            string    `json:"error,omitempty"`
+	StartedAt        time.Time `json:"started_at"`
+	FinishedAt       time.Time `json:"finished_at,omitzero"`
+	DurationSeconds  float64   `json:"duration_seconds"`
+	EstimatedCostUSD float64   `json:"estimated_cost_usd"`
+	// Restaurants is the number of ranked restaurants.
+	Restaurants int          `json:"restaurants"`
+	Top         []diff.Entry `json:"top"`
+	// PreviousRunID is the run New was compared against. It is empty for a
+	// job's first run, when nothing is new.
+	PreviousRunID string       `json:"previous_run_id,omitempty"`
+	New           []diff.Entry `json:"new"`
+	Failures      []Failure    `json:"failures"`
+	Files         []string     `json:"files,omitempty"`
+}
+
+// Failure is an item a stage could not process.
+type Failure struct {
+	Stage string `json:"stage"`
+	Item  string `json:"item"`
+	Error string `json:"error"`
+}
+
+// NewSummary summarizes a finished run from its report and its ranked
+// restaurants, keeping the top restaurants (DefaultTop if top is 0).
+func NewSummary(r *report.Report, restaurants []maps.Restaurant, top int) *Summary {
+	if top <= 0 {
+		top = DefaultTop
+	}
+	entries := diff.Entries(restaurants)
+	return &Summary{
+		RunID:            r.RunID,
+		Status:           r.Status,
+		Error:            r.Error,
+		StartedAt:        r.StartedAt,
+		FinishedAt:       r.FinishedAt,
+		DurationSeconds:  r.DurationSeconds,
+		EstimatedCostUSD: r.EstimatedCostUSD,
+		Restaurants:      len(entries),
+		Top:              entries[:min(top, len(entries))],
+		New:              []diff.Entry{},
+		Failures:         []Failure{},
+	}
+}
+
+// CompareTo records the restaurants that were not in the previous run.
+func (s *Summary) CompareTo(previousRunID string, previous, restaurants []maps.Restaurant) {
+	s.PreviousRunID = previousRunID
+	s.New = diff.Compare(previousRunID, previous, s.RunID, restaurants).Added
+	if s.New == nil {
+		s.New = []diff.Entry{}
+	}
+}
+
+// AddFailures records the items that failed in each stage of a run.
+func (s *Summary) AddFailures(state *run.State) {
+	for _, stage := range state.Stages {
+		for _, f := range stage.Failed {
+			s.Failures = append(s.Failures, Failure{Stage: stage.Name, Item: f.Item, Error: f.Error})
+		}
+	}
+}
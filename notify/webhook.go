@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/diff"
+)
+
+// Format is the body a webhook is sent.
+type Format string
+
+const (
+	// FormatJSON sends the Summary as JSON.
+	FormatJSON Format = "json"
+	// FormatSlack sends a Slack incoming webhook message, which Mattermost,
+	// Discord's Slack-compatible endpoint and others accept too.
+	FormatSlack Format = "slack"
+)
+
+// maxListed is the most new restaurants or failures listed in a message.
+const maxListed = 10
+
+// Webhook is a Sink that POSTs summaries to a URL.
+type Webhook struct {
+	URL string `json:"url"`
+	// Format is the body sent. The default is FormatJSON.
+	Format Format `json:"format,omitempty"`
+	// Client sends the requests. The default is a client with a 30 second
+	// timeout.
+	Client *http.Client `json:"-"`
+}
+
+var defaultClient = &http.Client{Timeout: 30 * time.Second}
+
+// Validate checks the webhook's URL and format.
+func (w *Webhook) Validate() error {
+	if !strings.HasPrefix(w.URL, "http://") && !strings.HasPrefix(w.URL, "https://") {
+		return fmt.Errorf("invalid webhook URL %q", w.URL)
+	}
+	switch w.Format {
+	case "", FormatJSON, FormatSlack:
+		return nil
+	default:
+		return fmt.Errorf("unknown webhook format %q (expected json or slack)", w.Format)
+	}
+}
+
+// Send POSTs the summary to the webhook.
+func (w *Webhook) Send(ctx context.Context, summary *Summary) error {
+	var body any = summary
+	if w.Format == FormatSlack {
+		body = map[string]string{"text": SlackText(summary)}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error marshaling notification: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending webhook: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// SlackText renders a summary in Slack's mrkdwn.
+func SlackText(s *Summary) string {
+	var b strings.Builder
+
+	name := "r/" + s.Subreddit
+	if s.Job != "" {
+		name = s.Job
+	}
+	fmt.Fprintf(&b, "*%s*: run `%s` %s in %s", escape(name), s.RunID, s.Status,
+		time.Duration(s.DurationSeconds*float64(time.Second)).Round(time.Second))
+	if s.EstimatedCostUSD > 0 {
+		fmt.Fprintf(&b, " (est. $%.2f)", s.EstimatedCostUSD)
+	}
+	fmt.Fprintf(&b, ", %d restaurants ranked\n", s.Restaurants)
+	if s.Error != "" {
+		fmt.Fprintf(&b, "> %s\n", escape(s.Error))
+	}
+
+	if len(s.Top) > 0 {
+		fmt.Fprintf(&b, "\n*Top %d*\n", len(s.Top))
+		for _, e := range s.Top {
+			fmt.Fprintf(&b, "%d. %s · %.1f★ · %d upvotes\n", e.Rank, slackLink(e), e.Rating, e.Upvotes)
+		}
+	}
+
+	if s.PreviousRunID != "" {
+		fmt.Fprintf(&b, "\n*New since `%s`* (%d)\n", s.PreviousRunID, len(s.New))
+		for _, e := range s.New[:min(maxListed, len(s.New))] {
+			fmt.Fprintf(&b, "• #%d %s\n", e.Rank, slackLink(e))
+		}
+		if len(s.New) > maxListed {
+			fmt.Fprintf(&b, "…and %d more\n", len(s.New)-maxListed)
+		}
+	}
+
+	if len(s.Failures) > 0 {
+		fmt.Fprintf(&b, "\n*Failures* (%d)\n", len(s.Failures))
+		for _, f := range s.Failures[:min(maxListed, len(s.Failures))] {
+			fmt.Fprintf(&b, "• %s: %s: %s\n", f.Stage, escape(f.Item), escape(f.Error))
+		}
+		if len(s.Failures) > maxListed {
+			fmt.Fprintf(&b, "…and %d more\n", len(s.Failures)-maxListed)
+		}
+	}
+
+	if len(s.Files) > 0 {
+		fmt.Fprintf(&b, "\nFiles: %s\n", escape(strings.Join(s.Files, ", ")))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// slackLink renders an entry's name as a link to its Google Maps page.
+func slackLink(e diff.Entry) string {
+	name := strings.NewReplacer("|", "¦").Replace(escape(e.Name))
+	if e.GoogleMapsUrl == "" {
+		return name
+	}
+	return fmt.Sprintf("<%s|%s>", escape(e.GoogleMapsUrl), name)
+}
+
+// escape escapes the characters Slack treats as control characters.
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
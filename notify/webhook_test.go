@@ -0,0 +1,133 @@
+package notify_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+	"github.com/tonyjhuang/reddit-to-gmap/notify"
+	"github.com/tonyjhuang/reddit-to-gmap/report"
+	"github.com/tonyjhuang/reddit-to-gmap/run"
+)
+
+func restaurant(name string, upvotes int) maps.Restaurant {
+	return maps.Restaurant{
+		Name:      name,
+		Upvotes:   upvotes,
+		RedditUrl: "https://www.reddit.com/r/foodnyc/comments/" + strings.ToLower(name),
+		GoogleMapsData: maps.GoogleMapsData{
+			Name:          name,
+			Rating:        4.5,
+			GoogleMapsUrl: "https://maps.google.com/?q=" + name,
+		},
+	}
+}
+
+func newSummary(t *testing.T) *notify.Summary {
+	t.Helper()
+
+	r := report.New("foodnyc-20260101-000000", "schedule")
+	r.Finish("completed", nil)
+	previous := []maps.Restaurant{restaurant("Joe's", 50)}
+	current := []maps.Restaurant{restaurant("Joe's", 80), restaurant("Lucali", 60), restaurant("L&B", 40)}
+
+	summary := notify.NewSummary(r, current, 2)
+	summary.Subreddit = "foodnyc"
+	summary.CompareTo("foodnyc-20251201-000000", previous, current)
+
+	state := run.New(t.TempDir(), r.RunID, "schedule", nil)
+	state.Stage("full_restaurants").Fail("Nowhere Diner", io.ErrUnexpectedEOF)
+	summary.AddFailures(state)
+	return summary
+}
+
+// capture starts a webhook stand-in that records the last request body.
+func capture(t *testing.T, status int) (*httptest.Server, *[]byte) {
+	t.Helper()
+
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s with Content-Type %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &body
+}
+
+func TestWebhookSendsJSONSummary(t *testing.T) {
+	server, body := capture(t, http.StatusNoContent)
+	webhook := &notify.Webhook{URL: server.URL, Client: server.Client()}
+
+	if err := webhook.Send(t.Context(), newSummary(t)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var got notify.Summary
+	if err := json.Unmarshal(*body, &got); err != nil {
+		t.Fatalf("unmarshal body: %v", err)
+	}
+	if got.Restaurants != 3 || len(got.Top) != 2 || got.Top[0].Name != "Joe's" {
+		t.Errorf("got %d restaurants and top %+v, want 3 restaurants and the top 2", got.Restaurants, got.Top)
+	}
+	if len(got.New) != 2 || got.New[0].Name != "Lucali" || got.New[1].Name != "L&B" {
+		t.Errorf("got new %+v, want Lucali and L&B", got.New)
+	}
+	if len(got.Failures) != 1 || got.Failures[0].Stage != "full_restaurants" || got.Failures[0].Item != "Nowhere Diner" {
+		t.Errorf("got failures %+v, want the full_restaurants failure", got.Failures)
+	}
+}
+
+func TestWebhookSendsSlackMessage(t *testing.T) {
+	server, body := capture(t, http.StatusOK)
+	webhook := &notify.Webhook{URL: server.URL, Format: notify.FormatSlack, Client: server.Client()}
+
+	if err := webhook.Send(t.Context(), newSummary(t)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var got struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(*body, &got); err != nil {
+		t.Fatalf("unmarshal body: %v", err)
+	}
+	for _, want := range []string{
+		"*r/foodnyc*: run `foodnyc-20260101-000000` completed",
+		"1. <https://maps.google.com/?q=Joe's|Joe's>",
+		"*New since `foodnyc-20251201-000000`* (2)",
+		"• #3 <https://maps.google.com/?q=L&amp;B|L&amp;B>",
+		"• full_restaurants: Nowhere Diner: unexpected EOF",
+	} {
+		if !strings.Contains(got.Text, want) {
+			t.Errorf("text is missing %q:\n%s", want, got.Text)
+		}
+	}
+}
+
+func TestWebhookReportsErrorStatus(t *testing.T) {
+	server, _ := capture(t, http.StatusBadRequest)
+	webhook := &notify.Webhook{URL: server.URL, Client: server.Client()}
+
+	err := webhook.Send(t.Context(), newSummary(t))
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("got error %v, want one for the 400 response", err)
+	}
+}
+
+func TestWebhookValidate(t *testing.T) {
+	for _, webhook := range []notify.Webhook{
+		{URL: "hooks.slack.com/services/x"},
+		{URL: "https://example.com", Format: "xml"},
+	} {
+		if err := webhook.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", webhook)
+		}
+	}
+}
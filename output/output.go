@@ -0,0 +1,118 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// DefaultDir is the directory outputs are written to.
+	DefaultDir = "out"
+	// DefaultFilenameTemplate reproduces the historical name, e.g. foodnyc_20250602_month.csv.
+	DefaultFilenameTemplate = "{subreddit}_{date}_{time_range}.{format}"
+	// FeedFilenameTemplate names the Atom feed. Unlike the other outputs it
+	// is updated in place by each run, so its name has no date.
+	FeedFilenameTemplate = "{subreddit}_{time_range}.atom"
+	// Stdout, given as the directory or file name, streams output to standard output.
+	Stdout = "-"
+)
+
+// Vars are the values substituted into a filename template.
+type Vars struct {
+	Subreddit string
+	Date      string
+	TimeRange string
+	Job       string
+	Format    string
+}
+
+// Filename expands the {subreddit}, {date}, {time_range}, {job} and {format}
+// placeholders of a filename template.
+func Filename(template string, vars Vars) string {
+	return strings.NewReplacer(
+		"{subreddit}", vars.Subreddit,
+		"{date}", vars.Date,
+		"{time_range}", vars.TimeRange,
+		"{job}", vars.Job,
+		"{format}", vars.Format,
+	).Replace(template)
+}
+
+// File is an output file that only appears at its final path once Commit
+// succeeds, so a failed run never leaves a truncated file behind. Data is
+// written to a temporary file in the same directory and renamed on commit.
+type File struct {
+	file      *os.File
+	path      string
+	stdout    bool
+	committed bool
+}
+
+// Create opens an output file named filename in dir. The filename may
+// include subdirectories, which are created as needed, but must stay inside
+// dir. If either is Stdout, writes go straight to standard output instead.
+func Create(dir, filename string) (*File, error) {
+	if dir == Stdout || filename == Stdout {
+		return &File{file: os.Stdout, path: Stdout, stdout: true}, nil
+	}
+	if !filepath.IsLocal(filename) {
+		return nil, fmt.Errorf("output file %q is outside %s", filename, dir)
+	}
+
+	path := filepath.Join(dir, filename)
+
+	// Create output directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("error creating output directory: %v", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("error creating output file: %v", err)
+	}
+
+	return &File{file: file, path: path}, nil
+}
+
+// Write writes to the pending file.
+func (f *File) Write(p []byte) (int, error) {
+	return f.file.Write(p)
+}
+
+// Commit moves the written data to its final path.
+func (f *File) Commit() error {
+	if f.stdout || f.committed {
+		return nil
+	}
+	if err := f.file.Close(); err != nil {
+		os.Remove(f.file.Name())
+		return fmt.Errorf("error closing output file: %v", err)
+	}
+	if err := os.Chmod(f.file.Name(), 0644); err != nil {
+		os.Remove(f.file.Name())
+		return fmt.Errorf("error setting output file permissions: %v", err)
+	}
+	if err := os.Rename(f.file.Name(), f.path); err != nil {
+		os.Remove(f.file.Name())
+		return fmt.Errorf("error moving output file into place: %v", err)
+	}
+	f.committed = true
+	return nil
+}
+
+// Close discards the pending file if it was not committed. It is safe to
+// defer Close right after Create.
+func (f *File) Close() error {
+	if f.stdout || f.committed {
+		return nil
+	}
+	f.file.Close()
+	return os.Remove(f.file.Name())
+}
+
+// Path returns the final path of the file, or Stdout.
+func (f *File) Path() string {
+	return f.path
+}
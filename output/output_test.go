@@ -0,0 +1,49 @@
+package output_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tonyjhuang/reddit-to-gmap/output"
+)
+
+func TestCreateInSubdirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+	filename := output.Filename("{job}/{subreddit}_{date}.{format}", output.Vars{
+		Subreddit: "foodnyc",
+		Date:      "20260201",
+		Job:       "weekly",
+		Format:    "csv",
+	})
+
+	f, err := output.Create(dir, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte("name\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := filepath.Join(dir, "weekly", "foodnyc_20260201.csv")
+	if f.Path() != want {
+		t.Errorf("Path() = %q, want %q", f.Path(), want)
+	}
+	if data, err := os.ReadFile(want); err != nil || string(data) != "name\n" {
+		t.Errorf("ReadFile() = %q, %v", data, err)
+	}
+}
+
+func TestCreateOutsideDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, filename := range []string{"../foodnyc.csv", "a/../../foodnyc.csv", "/tmp/foodnyc.csv", ""} {
+		if f, err := output.Create(dir, filename); err == nil {
+			f.Close()
+			t.Errorf("Create(%q) succeeded, want an error", filename)
+		}
+	}
+}
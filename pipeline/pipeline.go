@@ -0,0 +1,211 @@
+// Package pipeline runs a sequence of typed stages, each consuming the output
+// of the one before it. The runner handles what every stage needs the same
+// way: caching stage outputs, timing and counting them in the run report,
+// retrying failures and checkpointing stages that process items in batches.
+//
+// Stages are written against the typed Stage and Batched interfaces and
+// wrapped into Steps, which can be put together at run time, e.g. from a job's
+// configuration. Validate checks that the steps fit together before anything
+// runs.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"time"
+)
+
+// None is the input of the first stage of a pipeline.
+type None struct{}
+
+// Stage is a step of the pipeline that turns its whole input into its output
+// in one go.
+type Stage[In, Out any] interface {
+	Name() string
+	Run(ctx context.Context, in In) (Out, error)
+}
+
+// Batched is a stage whose input is a list of independent items. The runner
+// processes the items in batches and checkpoints after every batch, so a
+// resumed run only processes the items that are left.
+type Batched[Item, Result any] interface {
+	Name() string
+	// Key identifies an item in the run state. It must be stable across runs.
+	Key(item Item) string
+	Process(ctx context.Context, batch []Item) ([]Result, error)
+	// Combine turns the results of all batches, including those restored from
+	// a checkpoint, into the stage's output.
+	Combine(results []Result) []Result
+}
+
+// Step is a stage wrapped with its runner options. Steps of different types
+// can be kept in one list and chained at run time.
+//
+// After a step runs, the runner calls Report(*report.Report) on the stage if
+// it has that method, so it can add API usage and other details to the
+// report, and then Close() if it has that method.
+type Step struct {
+	name    string
+	in, out reflect.Type
+	stage   any
+
+	cached    bool
+	cacheKey  string
+	retries   int
+	backoff   time.Duration
+	batchSize int
+	pace      time.Duration
+	tolerate  bool
+
+	run  func(ctx context.Context, r *Runner, s *Step, in any) (any, error)
+	load func(cacheKey string) (any, error)
+}
+
+// Option configures a Step.
+type Option func(*Step)
+
+// Cache stores the step's output in the cache under the runner's cache key
+// followed by suffix. A run starts after the last step with a cached output.
+func Cache(suffix string) Option {
+	return func(s *Step) {
+		s.cached = true
+		s.cacheKey = suffix
+	}
+}
+
+// Retry retries a failed stage run, or a failed batch, up to retries times.
+// The delay before the first retry is backoff; it doubles on every attempt.
+func Retry(retries int, backoff time.Duration) Option {
+	return func(s *Step) {
+		s.retries = retries
+		s.backoff = backoff
+	}
+}
+
+// BatchSize sets how many items of a Batched stage are processed at once.
+// The default is 1.
+func BatchSize(n int) Option {
+	return func(s *Step) {
+		s.batchSize = n
+	}
+}
+
+// Pace waits d between batches, e.g. to stay under an API's rate limit.
+func Pace(d time.Duration) Option {
+	return func(s *Step) {
+		s.pace = d
+	}
+}
+
+// TolerateFailures records batches that still fail after retrying as failed
+// items in the run state and carries on, instead of stopping the run.
+func TolerateFailures() Option {
+	return func(s *Step) {
+		s.tolerate = true
+	}
+}
+
+// New wraps a stage into a step.
+func New[In, Out any](stage Stage[In, Out], opts ...Option) Step {
+	s := newStep[In, Out](stage.Name(), stage, opts)
+	s.run = func(ctx context.Context, r *Runner, s *Step, in any) (any, error) {
+		var out Out
+		err := s.retry(ctx, func() error {
+			var err error
+			out, err = stage.Run(ctx, in.(In))
+			return err
+		})
+		return out, err
+	}
+	return s
+}
+
+// NewBatched wraps a batched stage into a step.
+func NewBatched[Item, Result any](stage Batched[Item, Result], opts ...Option) Step {
+	s := newStep[[]Item, []Result](stage.Name(), stage, opts)
+	s.run = func(ctx context.Context, r *Runner, s *Step, in any) (any, error) {
+		return runBatched(ctx, r, s, stage, in.([]Item))
+	}
+	return s
+}
+
+func newStep[In, Out any](name string, stage any, opts []Option) Step {
+	s := Step{
+		name:      name,
+		in:        reflect.TypeFor[In](),
+		out:       reflect.TypeFor[Out](),
+		stage:     stage,
+		batchSize: 1,
+		load: func(cacheKey string) (any, error) {
+			return readCache[Out](cacheKey)
+		},
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// Name returns the name of the step's stage.
+func (s Step) Name() string {
+	return s.name
+}
+
+// Validate checks that every step accepts the output of the step before it,
+// and that the first step takes None.
+func Validate(steps []Step) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("pipeline has no stages")
+	}
+	if want := reflect.TypeFor[None](); steps[0].in != want {
+		return fmt.Errorf("stage %s cannot start the pipeline: it takes %s", steps[0].name, steps[0].in)
+	}
+	for i := 1; i < len(steps); i++ {
+		prev, next := steps[i-1], steps[i]
+		if !prev.out.AssignableTo(next.in) {
+			return fmt.Errorf("stage %s cannot follow %s: it takes %s, not %s", next.name, prev.name, next.in, prev.out)
+		}
+	}
+	return nil
+}
+
+// retry calls fn until it succeeds, the step's retries are used up or ctx is
+// done.
+func (s *Step) retry(ctx context.Context, fn func() error) error {
+	backoff := s.backoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || ctx.Err() != nil || attempt >= s.retries {
+			return err
+		}
+		slog.Warn("Stage failed, retrying", "stage", s.name, "attempt", attempt+1, "wait", backoff, "error", err)
+		if err := sleep(ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// sleep waits for d, returning early with the context's error if ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// count returns the number of items in a stage's input or output, or 0 if it
+// is not a list.
+func count(v any) int {
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Slice {
+		return value.Len()
+	}
+	return 0
+}
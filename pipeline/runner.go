@@ -0,0 +1,253 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+
+	"github.com/tonyjhuang/reddit-to-gmap/cache"
+	"github.com/tonyjhuang/reddit-to-gmap/report"
+	"github.com/tonyjhuang/reddit-to-gmap/run"
+)
+
+// Runner runs steps for a single run.
+type Runner struct {
+	// CacheKey is the start of the cache key of every cached step.
+	CacheKey string
+	UseCache bool
+	// State holds the checkpoints of batched steps. Without it, batched steps
+	// are not checkpointed.
+	State *run.State
+	// Report is where each step's counts, timing and details are recorded.
+	Report *report.Report
+}
+
+// Run validates the steps and runs them in order, returning the output of
+// the last one. If a step's output is cached, the run starts after the last
+// such step.
+func (r *Runner) Run(ctx context.Context, steps []Step) (any, error) {
+	if err := Validate(steps); err != nil {
+		return nil, err
+	}
+	if r.Report == nil {
+		r.Report = report.New("", "")
+	}
+
+	var value any = None{}
+	start := 0
+	if r.UseCache {
+		for i := len(steps) - 1; i >= 0; i-- {
+			step := &steps[i]
+			if !step.cached || !cache.CacheExists(r.cacheKey(step)) {
+				continue
+			}
+			cached, err := step.load(r.cacheKey(step))
+			if err != nil {
+				return nil, err
+			}
+			slog.Info("Found cached data", "stage", step.name, "cache_key", r.cacheKey(step), "items", count(cached))
+			r.Report.Stage(step.name).Finish(count(cached))
+			value, start = cached, i+1
+			break
+		}
+	}
+
+	for i := start; i < len(steps); i++ {
+		out, err := r.runStep(ctx, &steps[i], value)
+		if err != nil {
+			return nil, err
+		}
+		value = out
+	}
+	return value, nil
+}
+
+func (r *Runner) cacheKey(step *Step) string {
+	return r.CacheKey + step.cacheKey
+}
+
+// runStep runs a single step, records it in the report and caches its output.
+func (r *Runner) runStep(ctx context.Context, step *Step, in any) (any, error) {
+	stage := r.Report.Stage(step.name)
+	stage.Start(count(in))
+	defer func() {
+		if reporter, ok := step.stage.(interface{ Report(*report.Report) }); ok {
+			reporter.Report(r.Report)
+		}
+		if closer, ok := step.stage.(io.Closer); ok {
+			closer.Close()
+		}
+	}()
+
+	out, err := step.run(ctx, r, step, in)
+	if err != nil {
+		return nil, err
+	}
+
+	if step.cached {
+		if err := cache.WriteToCache(r.cacheKey(step), out); err != nil {
+			return nil, fmt.Errorf("error writing to cache: %v", err)
+		}
+	}
+	stage.Finish(count(out))
+	return out, nil
+}
+
+// cachedBatch is a completed batch of a cached batched step, kept in the
+// cache until the step finishes so that a rerun doesn't process it again.
+type cachedBatch[Result any] struct {
+	Keys    []string `json:"keys"`
+	Results []Result `json:"results"`
+}
+
+// partialCacheKey returns the cache key of the completed batches of a cached
+// batched step that has not finished.
+func (r *Runner) partialCacheKey(step *Step) string {
+	return r.cacheKey(step) + "_partial"
+}
+
+// runBatched processes the items of a batched stage that a resumed run has
+// not completed yet, checkpointing after every batch. A cached step also
+// keeps its completed batches in the cache, so that with UseCache a new run
+// of the same items skips them too.
+func runBatched[Item, Result any](ctx context.Context, r *Runner, step *Step, stage Batched[Item, Result], items []Item) ([]Result, error) {
+	progress := &run.Stage{Name: step.name}
+	var results []Result
+	if r.State != nil {
+		progress = r.State.Stage(step.name)
+		var err error
+		if results, err = run.Results[Result](r.State, step.name); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = stage.Key(item)
+	}
+
+	// A resumed run has its own checkpoint; a new one starts from the cache
+	var batches []cachedBatch[Result]
+	if step.cached && r.UseCache && len(progress.Completed) == 0 && cache.CacheExists(r.partialCacheKey(step)) {
+		cached, err := readCache[[]cachedBatch[Result]](r.partialCacheKey(step))
+		if err != nil {
+			return nil, err
+		}
+		for _, batch := range cached {
+			// Skip batches with items that are no longer in the input
+			if !slices.ContainsFunc(batch.Keys, func(key string) bool { return !slices.Contains(keys, key) }) {
+				batches = append(batches, batch)
+				results = append(results, batch.Results...)
+				progress.Completed = append(progress.Completed, batch.Keys...)
+			}
+		}
+		slog.Info("Found cached batches", "stage", step.name, "cache_key", r.partialCacheKey(step), "batches", len(batches))
+	}
+	checkpoint := func() error {
+		r.Report.Stage(step.name).Failed = len(progress.Failed)
+		if r.State == nil {
+			return nil
+		}
+		return run.Checkpoint(r.State, step.name, results)
+	}
+
+	progress.Start(keys)
+	pending := make([]Item, 0, len(progress.Pending))
+	for i, item := range items {
+		if !progress.Done(keys[i]) {
+			pending = append(pending, item)
+		}
+	}
+	if skipped := len(items) - len(pending); skipped > 0 {
+		slog.Info("Skipping items completed by an earlier attempt", "stage", step.name, "items", skipped)
+	}
+
+	for i := 0; i < len(pending); i += step.batchSize {
+		if i > 0 && step.pace > 0 {
+			if err := sleep(ctx, step.pace); err != nil {
+				return nil, err
+			}
+		}
+		batch := pending[i:min(i+step.batchSize, len(pending))]
+
+		var out []Result
+		err := step.retry(ctx, func() error {
+			var err error
+			out, err = stage.Process(ctx, batch)
+			return err
+		})
+		if err != nil && ctx.Err() != nil {
+			// Leave the batch pending for a resumed run.
+			if saveErr := checkpoint(); saveErr != nil {
+				slog.Warn("Could not save run state", "error", saveErr)
+			}
+			return nil, err
+		}
+
+		if err != nil {
+			for _, item := range batch {
+				progress.Fail(stage.Key(item), err)
+			}
+			if !step.tolerate {
+				if saveErr := checkpoint(); saveErr != nil {
+					slog.Warn("Could not save run state", "error", saveErr)
+				}
+				return nil, err
+			}
+			slog.Warn("Batch failed", "stage", step.name, "items", len(batch), "error", err)
+		} else {
+			results = append(results, out...)
+			done := cachedBatch[Result]{Results: out}
+			for _, item := range batch {
+				progress.Complete(stage.Key(item))
+				done.Keys = append(done.Keys, stage.Key(item))
+			}
+			if step.cached {
+				batches = append(batches, done)
+				if err := cache.WriteToCache(r.partialCacheKey(step), batches); err != nil {
+					return nil, fmt.Errorf("error writing to cache: %v", err)
+				}
+			}
+		}
+
+		if err := checkpoint(); err != nil {
+			return nil, err
+		}
+		slog.Info("Processed batch", "stage", step.name, "processed", i+len(batch), "total", len(pending))
+	}
+
+	if len(progress.Failed) > 0 {
+		slog.Warn("Some items failed; they are listed in the run state", "stage", step.name, "failed", len(progress.Failed))
+	}
+	if step.cached {
+		// The step's whole output is cached next
+		if err := cache.RemoveFromCache(r.partialCacheKey(step)); err != nil {
+			slog.Warn("Could not remove cached batches", "stage", step.name, "error", err)
+		}
+	}
+	return stage.Combine(results), nil
+}
+
+// readCache reads a cache entry as type T.
+func readCache[T any](cacheKey string) (T, error) {
+	var result T
+
+	cacheData, err := cache.ReadFromCache(cacheKey)
+	if err != nil {
+		return result, fmt.Errorf("error reading from cache: %v", err)
+	}
+
+	// Convert cached data back to type T using JSON marshaling/unmarshaling
+	jsonData, err := json.Marshal(cacheData.Data)
+	if err != nil {
+		return result, fmt.Errorf("error marshaling cache data: %v", err)
+	}
+
+	if err := json.Unmarshal(jsonData, &result); err != nil {
+		return result, fmt.Errorf("error unmarshaling cache data: %v", err)
+	}
+	return result, nil
+}
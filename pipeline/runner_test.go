@@ -0,0 +1,261 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tonyjhuang/reddit-to-gmap/pipeline"
+	"github.com/tonyjhuang/reddit-to-gmap/run"
+)
+
+// source is a stage that returns a fixed list of items.
+type source struct {
+	items []string
+	calls int
+}
+
+func (s *source) Name() string { return "source" }
+
+func (s *source) Run(ctx context.Context, _ pipeline.None) ([]string, error) {
+	s.calls++
+	return s.items, nil
+}
+
+// upper is a batched stage that upper-cases its items. Items listed in
+// failures fail that many times before they succeed, or always if the count
+// is negative.
+type upper struct {
+	failures map[string]int
+	batches  [][]string
+}
+
+func (u *upper) Name() string { return "upper" }
+
+func (u *upper) Key(item string) string { return item }
+
+func (u *upper) Process(ctx context.Context, batch []string) ([]string, error) {
+	u.batches = append(u.batches, slices.Clone(batch))
+	var results []string
+	for _, item := range batch {
+		if n := u.failures[item]; n != 0 {
+			u.failures[item] = n - 1
+			return nil, errors.New("cannot process " + item)
+		}
+		results = append(results, strings.ToUpper(item))
+	}
+	return results, nil
+}
+
+func (u *upper) Combine(results []string) []string {
+	slices.Sort(results)
+	return results
+}
+
+func TestRunSkipsCachedStage(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	steps := func(src *source, up *upper) []pipeline.Step {
+		return []pipeline.Step{
+			pipeline.New(src, pipeline.Cache("_items")),
+			pipeline.NewBatched(up, pipeline.BatchSize(2)),
+		}
+	}
+	r := &pipeline.Runner{CacheKey: "test", UseCache: true}
+	if _, err := r.Run(context.Background(), steps(&source{items: []string{"a", "b"}}, &upper{})); err != nil {
+		t.Fatal(err)
+	}
+
+	src, up := &source{items: []string{"a", "b"}}, &upper{}
+	out, err := r.Run(context.Background(), steps(src, up))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.calls != 0 {
+		t.Errorf("cached stage ran %d times, want 0", src.calls)
+	}
+	if len(up.batches) != 1 {
+		t.Errorf("stage after the cached one processed %d batches, want 1", len(up.batches))
+	}
+	if got := out.([]string); !slices.Equal(got, []string{"A", "B"}) {
+		t.Errorf("Run() = %v, want [A B]", got)
+	}
+
+	// Without UseCache, every stage runs again
+	r.UseCache = false
+	if _, err := r.Run(context.Background(), steps(src, up)); err != nil {
+		t.Fatal(err)
+	}
+	if src.calls != 1 {
+		t.Errorf("stage ran %d times without UseCache, want 1", src.calls)
+	}
+}
+
+func TestRunRetriesBatch(t *testing.T) {
+	src := &source{items: []string{"a", "b", "c"}}
+	up := &upper{failures: map[string]int{"c": 2}}
+	steps := []pipeline.Step{
+		pipeline.New(src),
+		pipeline.NewBatched(up, pipeline.BatchSize(2), pipeline.Retry(2, 0)),
+	}
+
+	out, err := (&pipeline.Runner{}).Run(context.Background(), steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out.([]string); !slices.Equal(got, []string{"A", "B", "C"}) {
+		t.Errorf("Run() = %v, want [A B C]", got)
+	}
+	// The second batch fails twice, then succeeds on the last retry
+	want := [][]string{{"a", "b"}, {"c"}, {"c"}, {"c"}}
+	if !slices.EqualFunc(up.batches, want, slices.Equal) {
+		t.Errorf("batches = %v, want %v", up.batches, want)
+	}
+}
+
+func TestRunGivesUpAfterRetries(t *testing.T) {
+	up := &upper{failures: map[string]int{"a": -1}}
+	steps := []pipeline.Step{
+		pipeline.New(&source{items: []string{"a"}}),
+		pipeline.NewBatched(up, pipeline.Retry(3, 0)),
+	}
+	if _, err := (&pipeline.Runner{}).Run(context.Background(), steps); err == nil {
+		t.Fatal("Run() succeeded, want an error")
+	}
+	if len(up.batches) != 4 {
+		t.Errorf("batch was tried %d times, want 4", len(up.batches))
+	}
+}
+
+func TestRunResumesAfterCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	items := []string{"a", "b", "c", "d", "e"}
+
+	// The third batch fails and stops the run after two were checkpointed
+	state := run.New(dir, "test-run", "test", nil)
+	up := &upper{failures: map[string]int{"e": 1}}
+	steps := []pipeline.Step{
+		pipeline.New(&source{items: items}),
+		pipeline.NewBatched(up, pipeline.BatchSize(2)),
+	}
+	if _, err := (&pipeline.Runner{State: state}).Run(context.Background(), steps); err == nil {
+		t.Fatal("Run() succeeded, want an error")
+	}
+
+	resumed, err := run.Load(dir, "test-run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	up.batches = nil
+	out, err := (&pipeline.Runner{State: resumed}).Run(context.Background(), steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"e"}}; !slices.EqualFunc(up.batches, want, slices.Equal) {
+		t.Errorf("resumed run processed %v, want %v", up.batches, want)
+	}
+	if got := out.([]string); !slices.Equal(got, []string{"A", "B", "C", "D", "E"}) {
+		t.Errorf("Run() = %v, want every item", got)
+	}
+}
+
+func TestRunReusesCachedBatches(t *testing.T) {
+	t.Chdir(t.TempDir())
+	items := []string{"a", "b", "c", "d", "e"}
+	steps := func(up *upper) []pipeline.Step {
+		return []pipeline.Step{
+			pipeline.New(&source{items: items}),
+			pipeline.NewBatched(up, pipeline.BatchSize(2), pipeline.Cache("_upper")),
+		}
+	}
+
+	// The third batch fails and stops the run, which has no run state
+	up := &upper{failures: map[string]int{"e": 1}}
+	r := &pipeline.Runner{CacheKey: "test", UseCache: true}
+	if _, err := r.Run(context.Background(), steps(up)); err == nil {
+		t.Fatal("Run() succeeded, want an error")
+	}
+
+	// A new run, not a resumed one, only processes the batch that failed
+	up.batches = nil
+	out, err := r.Run(context.Background(), steps(up))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"e"}}; !slices.EqualFunc(up.batches, want, slices.Equal) {
+		t.Errorf("second run processed %v, want %v", up.batches, want)
+	}
+	if got := out.([]string); !slices.Equal(got, []string{"A", "B", "C", "D", "E"}) {
+		t.Errorf("Run() = %v, want every item", got)
+	}
+
+	// Batches with items that are gone from the input are not reused
+	items = []string{"a", "b", "c", "d", "e"}
+	r.CacheKey = "other"
+	if _, err := r.Run(context.Background(), steps(&upper{failures: map[string]int{"e": 1}})); err == nil {
+		t.Fatal("Run() succeeded, want an error")
+	}
+	up = &upper{}
+	items = []string{"a", "b", "d", "e"}
+	if _, err := r.Run(context.Background(), steps(up)); err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"d", "e"}}; !slices.EqualFunc(up.batches, want, slices.Equal) {
+		t.Errorf("run with other items processed %v, want %v", up.batches, want)
+	}
+
+	// Without UseCache, every batch is processed again
+	up = &upper{}
+	r.UseCache = false
+	if _, err := r.Run(context.Background(), steps(up)); err != nil {
+		t.Fatal(err)
+	}
+	if len(up.batches) != 2 {
+		t.Errorf("run without UseCache processed %v, want every batch", up.batches)
+	}
+}
+
+func TestRunToleratesFailures(t *testing.T) {
+	state := run.New(t.TempDir(), "test-run", "test", nil)
+	up := &upper{failures: map[string]int{"b": -1}}
+	steps := []pipeline.Step{
+		pipeline.New(&source{items: []string{"a", "b", "c"}}),
+		pipeline.NewBatched(up, pipeline.Retry(1, 0), pipeline.TolerateFailures()),
+	}
+
+	r := &pipeline.Runner{State: state}
+	out, err := r.Run(context.Background(), steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out.([]string); !slices.Equal(got, []string{"A", "C"}) {
+		t.Errorf("Run() = %v, want [A C]", got)
+	}
+
+	progress := state.Stage("upper")
+	if len(progress.Failed) != 1 || progress.Failed[0].Item != "b" {
+		t.Errorf("failed items = %+v, want b", progress.Failed)
+	}
+	if !slices.Equal(progress.Completed, []string{"a", "c"}) {
+		t.Errorf("completed items = %v, want [a c]", progress.Completed)
+	}
+	if failed := r.Report.Stage("upper").Failed; failed != 1 {
+		t.Errorf("report has %d failed items, want 1", failed)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	src := pipeline.New(&source{})
+	up := pipeline.NewBatched(&upper{})
+	if err := pipeline.Validate([]pipeline.Step{src, up}); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if err := pipeline.Validate([]pipeline.Step{up}); err == nil {
+		t.Error("Validate() accepted a pipeline that doesn't start with None")
+	}
+	if err := pipeline.Validate(nil); err == nil {
+		t.Error("Validate() accepted an empty pipeline")
+	}
+}
@@ -1,26 +1,82 @@
 package reddit
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
-	baseURL                 = "https://oauth.reddit.com"
-	tokenURL                = "https://www.reddit.com/api/v1/access_token"
+	defaultBaseURL          = "https://oauth.reddit.com"
+	defaultTokenURL         = "https://www.reddit.com/api/v1/access_token"
+	defaultUserAgent        = "reddit-to-gmap/1.0"
 	placeholderClientID     = "YOUR_CLIENT_ID"
 	placeholderClientSecret = "YOUR_CLIENT_SECRET"
+
+	// tokenRefreshMargin is how long before expiry the access token is renewed.
+	tokenRefreshMargin = time.Minute
+	// maxRetries is how many times a request is retried after a 429 or 5xx response.
+	maxRetries = 4
+	// initialBackoff is the first retry delay; it doubles on every attempt.
+	initialBackoff = 2 * time.Second
+	// maxErrorBodyLength caps how much of an error response is kept in an APIError.
+	maxErrorBodyLength = 512
+	// maxLimitPerRequest is the largest page size Reddit serves.
+	maxLimitPerRequest = 100
 )
 
 type Client struct {
-	httpClient   *http.Client
-	token        string
-	clientID     string
-	clientSecret string
+	httpClient     *http.Client
+	baseURL        string
+	tokenURL       string
+	userAgent      string
+	initialBackoff time.Duration
+	token          string
+	tokenExpiry    time.Time
+	clientID       string
+	clientSecret   string
+	requests       int
+
+	// Rate limit state from the most recent X-Ratelimit-* response headers.
+	rateLimitKnown     bool
+	rateLimitRemaining float64
+	rateLimitReset     time.Time
+}
+
+// APIError is returned when Reddit responds with a non-2xx status.
+type APIError struct {
+	StatusCode int
+	Status     string
+	URL        string
+	Body       string // Start of the response body, for diagnostics
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("reddit API request to %s failed: %s: %s", e.URL, e.Status, e.Body)
+}
+
+// Temporary reports whether the request may succeed if retried later.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	if len(body) > maxErrorBodyLength {
+		body = body[:maxErrorBodyLength]
+	}
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		URL:        resp.Request.URL.Redacted(),
+		Body:       strings.TrimSpace(string(body)),
+	}
 }
 
 type TokenResponse struct {
@@ -30,13 +86,7 @@ type TokenResponse struct {
 }
 
 type Post struct {
-	Data struct {
-		Title     string `json:"title"`
-		Permalink string `json:"permalink"`
-		Selftext  string `json:"selftext"` // Description/body of the post
-		Score     int    `json:"score"`    // Number of upvotes
-		// Add more fields as needed
-	} `json:"data"`
+	Data PostData `json:"data"`
 }
 
 type ListingResponse struct {
@@ -48,87 +98,278 @@ type ListingResponse struct {
 	} `json:"data"`
 }
 
-func NewClient(clientID, clientSecret string) *Client {
-	return &Client{
-		httpClient:   &http.Client{},
-		clientID:     clientID,
-		clientSecret: clientSecret,
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL sets the API base URL, e.g. to point at a local stand-in server.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithTokenURL sets the OAuth token endpoint.
+func WithTokenURL(tokenURL string) Option {
+	return func(c *Client) {
+		c.tokenURL = tokenURL
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for all requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTransport sets the transport of the client's HTTP client.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.httpClient = &http.Client{Transport: transport, Timeout: c.httpClient.Timeout}
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
 	}
 }
 
-func (c *Client) getToken() error {
+// WithBackoff sets the delay before the first retry of a throttled or failed request.
+func WithBackoff(initialBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.initialBackoff = initialBackoff
+	}
+}
+
+// Requests returns the number of HTTP requests the client has made,
+// including token requests and retries.
+func (c *Client) Requests() int {
+	return c.requests
+}
+
+func NewClient(clientID, clientSecret string, opts ...Option) *Client {
+	c := &Client{
+		httpClient:     &http.Client{},
+		baseURL:        defaultBaseURL,
+		tokenURL:       defaultTokenURL,
+		userAgent:      defaultUserAgent,
+		initialBackoff: initialBackoff,
+		clientID:       clientID,
+		clientSecret:   clientSecret,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Client) getToken(ctx context.Context) error {
 	data := url.Values{}
 	data.Set("grant_type", "client_credentials")
 
-	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.tokenURL, strings.NewReader(data.Encode()))
 	if err != nil {
 		return fmt.Errorf("error creating request: %v", err)
 	}
 
 	req.SetBasicAuth(c.clientID, c.clientSecret)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", c.userAgent)
 
+	c.requests++
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("error getting token: %v", err)
 	}
 	defer resp.Body.Close()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading token response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(resp, body)
+	}
+
 	var tokenResp TokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
 		return fmt.Errorf("error decoding token response: %v", err)
 	}
+	if tokenResp.AccessToken == "" {
+		return fmt.Errorf("token response did not include an access token")
+	}
 
 	c.token = tokenResp.AccessToken
+	c.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
 	return nil
 }
 
-func (c *Client) fetchPostsPage(subreddit string, limit int, after string, count int, timeRange string) ([]Post, string, error) {
-	url := fmt.Sprintf("%s/r/%s/top.json?limit=%d&t=%s", baseURL, subreddit, limit, timeRange)
-	if after != "" {
-		url += fmt.Sprintf("&after=%s&count=%d", after, count)
+// ensureToken fetches a new access token if there is none or the current one
+// is about to expire.
+func (c *Client) ensureToken(ctx context.Context) error {
+	if c.token != "" && time.Now().Before(c.tokenExpiry.Add(-tokenRefreshMargin)) {
+		return nil
 	}
+	return c.getToken(ctx)
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// updateRateLimit records the rate limit state reported in response headers.
+func (c *Client) updateRateLimit(header http.Header) {
+	remaining, err := strconv.ParseFloat(header.Get("X-Ratelimit-Remaining"), 64)
 	if err != nil {
-		return nil, "", fmt.Errorf("error creating request: %v", err)
+		return
 	}
+	reset, err := strconv.ParseFloat(header.Get("X-Ratelimit-Reset"), 64)
+	if err != nil {
+		return
+	}
+	c.rateLimitKnown = true
+	c.rateLimitRemaining = remaining
+	c.rateLimitReset = time.Now().Add(time.Duration(reset * float64(time.Second)))
+}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
-	req.Header.Set("User-Agent", "reddit-to-gmap/1.0")
+// waitForRateLimit blocks until the rate limit window resets if the last
+// response reported no remaining requests, or until ctx is done.
+func (c *Client) waitForRateLimit(ctx context.Context) error {
+	if !c.rateLimitKnown || c.rateLimitRemaining >= 1 {
+		return nil
+	}
+	if wait := time.Until(c.rateLimitReset); wait > 0 {
+		slog.Info("Reddit rate limit reached, waiting", "wait", wait.Round(time.Second))
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+	c.rateLimitKnown = false
+	return nil
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, "", fmt.Errorf("error getting posts: %v", err)
+// sleep waits for d, returning early with the context's error if ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
-	defer resp.Body.Close()
+}
 
-	body, err := io.ReadAll(resp.Body)
+// retryDelay returns how long to wait before retrying a throttled or failed
+// request, preferring the server's Retry-After header when present.
+func retryDelay(resp *http.Response, backoff time.Duration) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return backoff
+}
+
+// get performs an authenticated GET request and returns the response body.
+// It refreshes the token once on 401, honors the rate limit headers and
+// retries 429 and 5xx responses with exponential backoff.
+func (c *Client) get(ctx context.Context, requestURL string) ([]byte, error) {
+	backoff := c.initialBackoff
+	refreshed := false
+
+	for attempt := 0; ; attempt++ {
+		if err := c.ensureToken(ctx); err != nil {
+			return nil, err
+		}
+		if err := c.waitForRateLimit(ctx); err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %v", err)
+		}
+
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+		req.Header.Set("User-Agent", c.userAgent)
+
+		c.requests++
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error sending request: %v", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading response: %v", err)
+		}
+		c.updateRateLimit(resp.Header)
+
+		if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			return body, nil
+		}
+
+		apiErr := newAPIError(resp, body)
+		if resp.StatusCode == http.StatusUnauthorized && !refreshed {
+			// The token may have been revoked or expired early; get a new one and retry once.
+			c.token = ""
+			refreshed = true
+			continue
+		}
+		if apiErr.Temporary() && attempt < maxRetries {
+			wait := retryDelay(resp, backoff)
+			slog.Warn("Reddit request failed, retrying", "status", resp.Status, "wait", wait)
+			if err := sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+			backoff *= 2
+			continue
+		}
+		return nil, apiErr
+	}
+}
+
+func (c *Client) fetchPostsPage(ctx context.Context, subreddit string, limit int, after string, count int, listing Listing) ([]Post, string, error) {
+	params := listing.params()
+	params.Set("limit", strconv.Itoa(limit))
+	if after != "" {
+		params.Set("after", after)
+		params.Set("count", strconv.Itoa(count))
+	}
+	url := fmt.Sprintf("%s/r/%s/%s.json?%s", c.baseURL, subreddit, listing.Sort, params.Encode())
+
+	body, err := c.get(ctx, url)
 	if err != nil {
-		return nil, "", fmt.Errorf("error reading response: %v", err)
+		return nil, "", err
 	}
 
 	var listingResp ListingResponse
 	if err := json.Unmarshal(body, &listingResp); err != nil {
-		return nil, "", fmt.Errorf("error decoding response: %v\nbody: %s", err, body)
+		return nil, "", fmt.Errorf("error decoding listing response: %v", err)
 	}
 
-	// Prepend "reddit.com" to each post's permalink
+	// Prepend "reddit.com" to each post's permalink, which Reddit returns as
+	// a path
 	for i := range listingResp.Data.Children {
-		listingResp.Data.Children[i].Data.Permalink = "https://www.reddit.com" + listingResp.Data.Children[i].Data.Permalink
+		data := &listingResp.Data.Children[i].Data
+		if strings.HasPrefix(data.Permalink, "/") {
+			data.Permalink = "https://www.reddit.com" + data.Permalink
+		}
 	}
 
 	return listingResp.Data.Children, listingResp.Data.After, nil
 }
 
-func (c *Client) GetPosts(subreddit string, limit int, timeRange string) ([]Post, error) {
-	if c.token == "" {
-		if err := c.getToken(); err != nil {
-			return nil, err
-		}
+// GetPosts fetches up to limit top posts of a subreddit within timeRange.
+func (c *Client) GetPosts(ctx context.Context, subreddit string, limit int, timeRange string) ([]Post, error) {
+	return c.GetListing(ctx, subreddit, limit, Listing{Sort: ListingTop, TimeRange: timeRange})
+}
+
+// GetListing fetches up to limit posts of a subreddit from the given listing,
+// paginating as needed.
+func (c *Client) GetListing(ctx context.Context, subreddit string, limit int, listing Listing) ([]Post, error) {
+	if err := listing.Validate(); err != nil {
+		return nil, err
 	}
 
-	const maxLimitPerRequest = 100
 	var allPosts []Post
 	var after string
 	var count int
@@ -140,7 +381,7 @@ func (c *Client) GetPosts(subreddit string, limit int, timeRange string) ([]Post
 			remainingLimit = maxLimitPerRequest
 		}
 
-		posts, nextAfter, err := c.fetchPostsPage(subreddit, remainingLimit, after, count, timeRange)
+		posts, nextAfter, err := c.fetchPostsPage(ctx, subreddit, remainingLimit, after, count, listing)
 		if err != nil {
 			return nil, err
 		}
@@ -157,3 +398,42 @@ func (c *Client) GetPosts(subreddit string, limit int, timeRange string) ([]Post
 
 	return allPosts, nil
 }
+
+// GetPostsBetween fetches every post submitted to a subreddit in the window
+// [since, until), newest first. It pages through the new listing until it
+// reaches posts older than since. A zero until means no upper bound.
+func (c *Client) GetPostsBetween(ctx context.Context, subreddit string, since, until time.Time) ([]Post, error) {
+	listing := Listing{Sort: ListingNew}
+	var allPosts []Post
+	var after string
+	var count int
+
+	for {
+		posts, nextAfter, err := c.fetchPostsPage(ctx, subreddit, maxLimitPerRequest, after, count, listing)
+		if err != nil {
+			return nil, err
+		}
+		count += len(posts)
+
+		for _, post := range posts {
+			created := post.Data.Created()
+			if created.Before(since) {
+				// The listing is ordered by submission time, so everything after
+				// this post is older still.
+				return allPosts, nil
+			}
+			if until.IsZero() || created.Before(until) {
+				allPosts = append(allPosts, post)
+			}
+		}
+
+		if nextAfter == "" {
+			// Reddit stops serving listings after about 1000 posts, so the start
+			// of the window may not have been reached.
+			slog.Warn("Listing ended before the start of the window; the window may be incomplete",
+				"subreddit", subreddit, "posts", count, "since", since.Format(time.DateOnly))
+			return allPosts, nil
+		}
+		after = nextAfter
+	}
+}
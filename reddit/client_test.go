@@ -0,0 +1,250 @@
+package reddit_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/reddit"
+	"github.com/tonyjhuang/reddit-to-gmap/reddit/reddittest"
+)
+
+func newTestClient(t *testing.T) (*reddit.Client, *reddittest.Server) {
+	t.Helper()
+
+	server := reddittest.NewServer()
+	t.Cleanup(server.Close)
+	if err := server.LoadFixtures("testdata"); err != nil {
+		t.Fatalf("LoadFixtures: %v", err)
+	}
+
+	opts := append(server.Options(), reddit.WithBackoff(time.Millisecond))
+	return reddit.NewClient("id", "secret", opts...), server
+}
+
+func TestGetPostsPaginates(t *testing.T) {
+	client, server := newTestClient(t)
+	server.PageSize = 2
+
+	posts, err := client.GetPosts(t.Context(), "foodnyc", 5, "month")
+	if err != nil {
+		t.Fatalf("GetPosts: %v", err)
+	}
+
+	if len(posts) != 5 {
+		t.Fatalf("got %d posts, want 5", len(posts))
+	}
+	if got := len(server.Requests()); got != 3 {
+		t.Errorf("got %d listing requests, want 3", got)
+	}
+	if got, want := posts[0].Data.Permalink, "https://www.reddit.com/r/FoodNYC/comments/1qlqrrf/x/"; got != want {
+		t.Errorf("permalink = %q, want %q", got, want)
+	}
+	if got := posts[1].Data.Score; got != 515 {
+		t.Errorf("score = %d, want 515", got)
+	}
+}
+
+func TestGetPostsStopsAtLimit(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	posts, err := client.GetPosts(t.Context(), "foodnyc", 3, "month")
+	if err != nil {
+		t.Fatalf("GetPosts: %v", err)
+	}
+	if len(posts) != 3 {
+		t.Errorf("got %d posts, want 3", len(posts))
+	}
+}
+
+func TestGetPostsRefreshesRevokedToken(t *testing.T) {
+	client, server := newTestClient(t)
+
+	if _, err := client.GetPosts(t.Context(), "foodnyc", 1, "month"); err != nil {
+		t.Fatalf("GetPosts: %v", err)
+	}
+	server.RevokeToken()
+	if _, err := client.GetPosts(t.Context(), "foodnyc", 1, "month"); err != nil {
+		t.Fatalf("GetPosts after revocation: %v", err)
+	}
+
+	if got := server.TokenRequests(); got != 2 {
+		t.Errorf("got %d token requests, want 2", got)
+	}
+}
+
+func TestGetPostsRetriesTemporaryErrors(t *testing.T) {
+	client, server := newTestClient(t)
+	server.Fail(http.StatusTooManyRequests, http.StatusServiceUnavailable)
+
+	posts, err := client.GetPosts(t.Context(), "foodnyc", 2, "month")
+	if err != nil {
+		t.Fatalf("GetPosts: %v", err)
+	}
+	if len(posts) != 2 {
+		t.Errorf("got %d posts, want 2", len(posts))
+	}
+	if got := len(server.Requests()); got != 3 {
+		t.Errorf("got %d requests, want 3", got)
+	}
+}
+
+func TestGetPostsReturnsAPIError(t *testing.T) {
+	client, server := newTestClient(t)
+	server.Fail(http.StatusForbidden)
+
+	_, err := client.GetPosts(t.Context(), "foodnyc", 2, "month")
+
+	var apiErr *reddit.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got error %v, want *reddit.APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", apiErr.StatusCode, http.StatusForbidden)
+	}
+	if got := len(server.Requests()); got != 1 {
+		t.Errorf("got %d requests, want 1 (403 must not be retried)", got)
+	}
+}
+
+func TestGetPostsStopsRetryingWhenCanceled(t *testing.T) {
+	server := reddittest.NewServer()
+	t.Cleanup(server.Close)
+	server.Fail(http.StatusServiceUnavailable)
+
+	opts := append(server.Options(), reddit.WithBackoff(time.Hour))
+	client := reddit.NewClient("id", "secret", opts...)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := client.GetPosts(ctx, "foodnyc", 2, "month"); err == nil {
+		t.Fatal("GetPosts succeeded, want a cancellation error")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("GetPosts returned after %s, want it to stop waiting when canceled", elapsed)
+	}
+}
+
+func TestGetListingSearch(t *testing.T) {
+	client, server := newTestClient(t)
+	var review reddit.Post
+	review.Data.Title = "Review: Duzan"
+	if err := server.AddListing("foodnyc", reddit.ListingSearch, []reddit.Post{review}); err != nil {
+		t.Fatalf("AddListing: %v", err)
+	}
+
+	posts, err := client.GetListing(t.Context(), "foodnyc", 10, reddit.Listing{
+		Sort:       reddit.ListingSearch,
+		Query:      "review",
+		TimeRange:  "year",
+		SearchSort: "top",
+	})
+	if err != nil {
+		t.Fatalf("GetListing: %v", err)
+	}
+
+	if len(posts) != 1 || posts[0].Data.Title != "Review: Duzan" {
+		t.Errorf("got posts %+v, want the search fixture", posts)
+	}
+	if got, want := server.Requests()[0], "/r/foodnyc/search.json?limit=10&q=review&restrict_sr=1&sort=top&t=year"; got != want {
+		t.Errorf("request = %q, want %q", got, want)
+	}
+}
+
+func TestGetListingRejectsInvalidListing(t *testing.T) {
+	client, server := newTestClient(t)
+
+	for _, listing := range []reddit.Listing{
+		{Sort: "best"},
+		{Sort: reddit.ListingSearch},
+		{Sort: reddit.ListingHot, Query: "review"},
+	} {
+		if _, err := client.GetListing(t.Context(), "foodnyc", 10, listing); err == nil {
+			t.Errorf("GetListing(%+v) succeeded, want an error", listing)
+		}
+	}
+	if got := len(server.Requests()); got != 0 {
+		t.Errorf("got %d requests, want none", got)
+	}
+}
+
+func TestGetPostsBetween(t *testing.T) {
+	client, server := newTestClient(t)
+	server.PageSize = 2
+
+	// The fixture posts were submitted one day apart, newest first. They are
+	// served as Reddit returns them, with relative permalinks.
+	posts := readFixture(t, "testdata/foodnyc_top.json")
+	if err := server.AddListing("foodnyc", reddit.ListingNew, posts); err != nil {
+		t.Fatalf("AddListing: %v", err)
+	}
+	newest := posts[0].Data.Created()
+
+	window, err := client.GetPostsBetween(t.Context(), "foodnyc", newest.AddDate(0, 0, -2), newest)
+	if err != nil {
+		t.Fatalf("GetPostsBetween: %v", err)
+	}
+
+	var ids []string
+	for _, post := range window {
+		ids = append(ids, post.Data.ID)
+	}
+	if want := []string{"1qffxm9", "1q7p3if"}; !slices.Equal(ids, want) {
+		t.Errorf("got posts %q, want %q", ids, want)
+	}
+	if got, want := window[0].Data.Permalink, "https://www.reddit.com/r/FoodNYC/comments/1qffxm9/"; !strings.HasPrefix(got, want) {
+		t.Errorf("permalink = %q, want it to start with %q", got, want)
+	}
+	// Paging stops at the page containing the first post older than since.
+	if got := server.Requests()[len(server.Requests())-1]; !strings.Contains(got, "after=offset_2") {
+		t.Errorf("last request = %q, want the second page", got)
+	}
+}
+
+func TestGetListingKeepsAbsolutePermalinks(t *testing.T) {
+	client, server := newTestClient(t)
+	var relative, absolute reddit.Post
+	relative.Data.Permalink = "/r/foodnyc/comments/a1/joes/"
+	absolute.Data.Permalink = "https://www.reddit.com/r/foodnyc/comments/b2/duzan/"
+	if err := server.AddListing("foodnyc", reddit.ListingHot, []reddit.Post{relative, absolute}); err != nil {
+		t.Fatalf("AddListing: %v", err)
+	}
+
+	posts, err := client.GetListing(t.Context(), "foodnyc", 10, reddit.Listing{Sort: reddit.ListingHot})
+	if err != nil {
+		t.Fatalf("GetListing: %v", err)
+	}
+	want := []string{
+		"https://www.reddit.com/r/foodnyc/comments/a1/joes/",
+		"https://www.reddit.com/r/foodnyc/comments/b2/duzan/",
+	}
+	var got []string
+	for _, post := range posts {
+		got = append(got, post.Data.Permalink)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("permalinks = %q, want %q", got, want)
+	}
+}
+
+// readFixture reads the posts of a listing fixture as Reddit returned them.
+func readFixture(t *testing.T, path string) []reddit.Post {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listing reddit.ListingResponse
+	if err := json.Unmarshal(data, &listing); err != nil {
+		t.Fatal(err)
+	}
+	return listing.Data.Children
+}
@@ -0,0 +1,78 @@
+package reddit
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+)
+
+// Listing sorts supported by GetListing.
+const (
+	ListingTop           = "top"
+	ListingHot           = "hot"
+	ListingNew           = "new"
+	ListingRising        = "rising"
+	ListingControversial = "controversial"
+	ListingSearch        = "search"
+)
+
+// ListingSorts lists every supported listing sort.
+var ListingSorts = []string{ListingTop, ListingHot, ListingNew, ListingRising, ListingControversial, ListingSearch}
+
+var (
+	timeRanges  = []string{"hour", "day", "week", "month", "year", "all"}
+	searchSorts = []string{"relevance", "hot", "top", "new", "comments"}
+)
+
+// Listing selects which posts of a subreddit to fetch.
+type Listing struct {
+	Sort string `json:"sort"` // One of ListingSorts
+	// TimeRange applies to the top, controversial and search listings.
+	TimeRange string `json:"time_range,omitempty"`
+	// Query and SearchSort apply to the search listing only. Searches are
+	// restricted to the subreddit.
+	Query      string `json:"query,omitempty"`
+	SearchSort string `json:"search_sort,omitempty"`
+}
+
+// Validate checks that the listing's parameters are supported by its sort.
+func (l Listing) Validate() error {
+	if !slices.Contains(ListingSorts, l.Sort) {
+		return fmt.Errorf("unknown listing %q (expected one of %v)", l.Sort, ListingSorts)
+	}
+	if l.TimeRange != "" && !slices.Contains(timeRanges, l.TimeRange) {
+		return fmt.Errorf("unknown time range %q (expected one of %v)", l.TimeRange, timeRanges)
+	}
+	if l.Sort == ListingSearch {
+		if l.Query == "" {
+			return fmt.Errorf("the search listing requires a query")
+		}
+		if l.SearchSort != "" && !slices.Contains(searchSorts, l.SearchSort) {
+			return fmt.Errorf("unknown search sort %q (expected one of %v)", l.SearchSort, searchSorts)
+		}
+	} else if l.Query != "" {
+		return fmt.Errorf("a query is only supported by the search listing")
+	}
+	return nil
+}
+
+// params returns the listing-specific query parameters.
+func (l Listing) params() url.Values {
+	params := url.Values{}
+	switch l.Sort {
+	case ListingTop, ListingControversial:
+		if l.TimeRange != "" {
+			params.Set("t", l.TimeRange)
+		}
+	case ListingSearch:
+		params.Set("q", l.Query)
+		params.Set("restrict_sr", "1")
+		if l.TimeRange != "" {
+			params.Set("t", l.TimeRange)
+		}
+		if l.SearchSort != "" {
+			params.Set("sort", l.SearchSort)
+		}
+	}
+	return params
+}
@@ -0,0 +1,119 @@
+package reddit
+
+import (
+	"html"
+	"path"
+	"strings"
+	"time"
+)
+
+// PostData is the data of a submission ("t3") as returned by the Reddit API
+// and by Reddit data dumps.
+type PostData struct {
+	ID          string  `json:"id,omitempty"`
+	Name        string  `json:"name,omitempty"` // Fullname, e.g. "t3_1qffxm9"
+	Title       string  `json:"title"`
+	Permalink   string  `json:"permalink"`
+	Selftext    string  `json:"selftext"` // Description/body of the post
+	Score       int     `json:"score"`    // Number of upvotes
+	CreatedUTC  float64 `json:"created_utc,omitempty"`
+	Author      string  `json:"author,omitempty"`
+	Flair       string  `json:"link_flair_text,omitempty"`
+	NumComments int     `json:"num_comments,omitempty"`
+	UpvoteRatio float64 `json:"upvote_ratio,omitempty"`
+	URL         string  `json:"url,omitempty"`
+	IsSelf      bool    `json:"is_self,omitempty"`
+	Over18      bool    `json:"over_18,omitempty"`
+	// RemovedByCategory is set when a post was removed, e.g. "moderator" or "deleted".
+	RemovedByCategory string `json:"removed_by_category,omitempty"`
+
+	// Media fields used to find photos attached to the post.
+	PostHint      string                   `json:"post_hint,omitempty"`
+	IsGallery     bool                     `json:"is_gallery,omitempty"`
+	GalleryData   *GalleryData             `json:"gallery_data,omitempty"`
+	MediaMetadata map[string]MediaMetadata `json:"media_metadata,omitempty"`
+	Preview       *Preview                 `json:"preview,omitempty"`
+}
+
+// GalleryData orders the images of a gallery post.
+type GalleryData struct {
+	Items []struct {
+		MediaID string `json:"media_id"`
+		Caption string `json:"caption,omitempty"`
+	} `json:"items"`
+}
+
+// MediaMetadata describes one image of a gallery post.
+type MediaMetadata struct {
+	Status string     `json:"status,omitempty"`
+	Type   string     `json:"e,omitempty"` // e.g. "Image"
+	Mime   string     `json:"m,omitempty"`
+	Source MediaImage `json:"s"`
+}
+
+// MediaImage is a gallery image rendition.
+type MediaImage struct {
+	URL    string `json:"u,omitempty"`
+	Width  int    `json:"x,omitempty"`
+	Height int    `json:"y,omitempty"`
+}
+
+// Preview holds the preview images Reddit generates for link and image posts.
+type Preview struct {
+	Images []struct {
+		Source struct {
+			URL    string `json:"url"`
+			Width  int    `json:"width"`
+			Height int    `json:"height"`
+		} `json:"source"`
+	} `json:"images"`
+}
+
+// Created returns when the post was submitted.
+func (d PostData) Created() time.Time {
+	return time.Unix(int64(d.CreatedUTC), 0).UTC()
+}
+
+// Deleted reports whether the author deleted the post.
+func (d PostData) Deleted() bool {
+	return d.Author == "[deleted]" || d.Selftext == "[deleted]" || d.RemovedByCategory == "deleted"
+}
+
+// Removed reports whether the post was removed by its author, a moderator,
+// Reddit or an automated filter.
+func (d PostData) Removed() bool {
+	return d.RemovedByCategory != "" || d.Selftext == "[removed]" || d.Deleted()
+}
+
+// ImageURLs returns the full-size images attached to the post: gallery
+// images in gallery order, otherwise the preview image, otherwise the linked
+// URL if it points directly at an image.
+func (d PostData) ImageURLs() []string {
+	var urls []string
+	if d.GalleryData != nil {
+		for _, item := range d.GalleryData.Items {
+			if media, ok := d.MediaMetadata[item.MediaID]; ok && media.Source.URL != "" {
+				urls = append(urls, html.UnescapeString(media.Source.URL))
+			}
+		}
+	}
+	if len(urls) == 0 && d.Preview != nil {
+		for _, image := range d.Preview.Images {
+			if image.Source.URL != "" {
+				urls = append(urls, html.UnescapeString(image.Source.URL))
+			}
+		}
+	}
+	if len(urls) == 0 && !d.IsSelf && isImageURL(d.URL) {
+		urls = append(urls, d.URL)
+	}
+	return urls
+}
+
+func isImageURL(u string) bool {
+	switch strings.ToLower(path.Ext(strings.SplitN(u, "?", 2)[0])) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package reddit_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/reddit"
+)
+
+func TestPostMetadata(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	posts, err := client.GetPosts(t.Context(), "foodnyc", 5, "month")
+	if err != nil {
+		t.Fatalf("GetPosts: %v", err)
+	}
+
+	jerky, duzan, deleted := posts[0].Data, posts[1].Data, posts[4].Data
+
+	if jerky.ID != "1qlqrrf" || jerky.Name != "t3_1qlqrrf" || jerky.Flair != "Review" {
+		t.Errorf("got id %q, name %q, flair %q", jerky.ID, jerky.Name, jerky.Flair)
+	}
+	if got, want := jerky.Created(), time.Unix(1769000000, 0).UTC(); !got.Equal(want) {
+		t.Errorf("Created() = %v, want %v", got, want)
+	}
+	if jerky.Removed() {
+		t.Error("Removed() = true for a live post")
+	}
+	if !deleted.Removed() || !deleted.Deleted() {
+		t.Error("want the deleted post to be reported as removed and deleted")
+	}
+
+	if got, want := jerky.ImageURLs(), []string{"https://preview.redd.it/jerky.jpeg?width=1080&s=abc"}; !slices.Equal(got, want) {
+		t.Errorf("preview ImageURLs() = %q, want %q", got, want)
+	}
+	wantGallery := []string{"https://preview.redd.it/m2.jpg?width=1080&s=2", "https://preview.redd.it/m1.jpg?width=1080&s=1"}
+	if got := duzan.ImageURLs(); !slices.Equal(got, wantGallery) {
+		t.Errorf("gallery ImageURLs() = %q, want %q", got, wantGallery)
+	}
+	if got := posts[2].Data.ImageURLs(); len(got) != 0 {
+		t.Errorf("self post ImageURLs() = %q, want none", got)
+	}
+}
+
+func TestImageURLsFallsBackToLinkedImage(t *testing.T) {
+	post := reddit.PostData{URL: "https://i.imgur.com/abc.PNG"}
+	if got := post.ImageURLs(); !slices.Equal(got, []string{post.URL}) {
+		t.Errorf("ImageURLs() = %q, want the linked image", got)
+	}
+}
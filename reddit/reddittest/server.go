@@ -0,0 +1,278 @@
+// Package reddittest provides an in-process stand-in for the Reddit API,
+// serving listings and comment trees from fixtures.
+package reddittest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tonyjhuang/reddit-to-gmap/reddit"
+)
+
+// TokenPath is the path of the fake OAuth token endpoint.
+const TokenPath = "/api/v1/access_token"
+
+// Server is an httptest-based fake of the Reddit API. It serves
+// /api/v1/access_token, /r/{subreddit}/{sort}.json and
+// /r/{subreddit}/comments/{id}.json.
+type Server struct {
+	*httptest.Server
+
+	// PageSize caps the number of posts per listing page, regardless of the
+	// limit requested, so pagination can be exercised with small fixtures.
+	PageSize int
+
+	mu       sync.Mutex
+	listings map[string][]json.RawMessage
+	comments map[string]json.RawMessage
+	tokens   int
+	token    string
+	failures []int
+	requests []string
+}
+
+// NewServer starts a fake Reddit server with no fixtures. Callers must Close it.
+func NewServer() *Server {
+	s := &Server{
+		listings: make(map[string][]json.RawMessage),
+		comments: make(map[string]json.RawMessage),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+TokenPath, s.handleToken)
+	mux.HandleFunc("GET /r/{subreddit}/comments/{id}", s.handleComments)
+	mux.HandleFunc("GET /r/{subreddit}/{listing}", s.handleListing)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Options returns the client options that point a reddit.Client at this server.
+func (s *Server) Options() []reddit.Option {
+	return []reddit.Option{
+		reddit.WithBaseURL(s.URL),
+		reddit.WithTokenURL(s.URL + TokenPath),
+		reddit.WithHTTPClient(s.Client()),
+	}
+}
+
+// AddListing serves posts for a subreddit listing such as "top" or "new".
+func (s *Server) AddListing(subreddit, listing string, posts []reddit.Post) error {
+	children := make([]json.RawMessage, 0, len(posts))
+	for _, post := range posts {
+		data, err := json.Marshal(post)
+		if err != nil {
+			return fmt.Errorf("error marshaling post: %v", err)
+		}
+		children = append(children, data)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listings[listingKey(subreddit, listing)] = children
+	return nil
+}
+
+// AddComments serves a comment tree, in Reddit's response format, for a post ID.
+func (s *Server) AddComments(postID string, tree json.RawMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.comments[postID] = tree
+}
+
+// LoadFixtures loads every fixture in dir. Files named
+// <subreddit>_<listing>.json hold a Reddit listing response and files named
+// comments_<id>.json hold a comment tree response.
+func (s *Server) LoadFixtures(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading fixture: %v", err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+
+		if id, ok := strings.CutPrefix(name, "comments_"); ok {
+			s.AddComments(id, data)
+			continue
+		}
+
+		subreddit, listing, ok := strings.Cut(name, "_")
+		if !ok {
+			return fmt.Errorf("fixture %s is not named <subreddit>_<listing>.json", path)
+		}
+		var response struct {
+			Data struct {
+				Children []json.RawMessage `json:"children"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(data, &response); err != nil {
+			return fmt.Errorf("error parsing fixture %s: %v", path, err)
+		}
+		s.mu.Lock()
+		s.listings[listingKey(subreddit, listing)] = response.Data.Children
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// Fail makes the next API requests (not token requests) respond with the
+// given status codes, one per request, before normal service resumes.
+func (s *Server) Fail(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+// RevokeToken invalidates the current access token, so the next API request
+// gets a 401 until the client fetches a new one.
+func (s *Server) RevokeToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = ""
+}
+
+// Requests returns the request URIs of the API requests served so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// TokenRequests returns the number of token requests served so far.
+func (s *Server) TokenRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens
+}
+
+func listingKey(subreddit, listing string) string {
+	return strings.ToLower(subreddit) + "/" + listing
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := r.BasicAuth(); !ok {
+		http.Error(w, `{"error": 401}`, http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	s.tokens++
+	s.token = "token-" + strconv.Itoa(s.tokens)
+	token := s.token
+	s.mu.Unlock()
+
+	writeJSON(w, reddit.TokenResponse{AccessToken: token, TokenType: "bearer", ExpiresIn: 86400})
+}
+
+// authorize records the request and applies queued failures and token
+// checks. It returns false if a response has already been written.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r.URL.RequestURI())
+
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		http.Error(w, fmt.Sprintf(`{"message": %q, "error": %d}`, http.StatusText(status), status), status)
+		return false
+	}
+	if s.token == "" || r.Header.Get("Authorization") != "Bearer "+s.token {
+		http.Error(w, `{"message": "Unauthorized", "error": 401}`, http.StatusUnauthorized)
+		return false
+	}
+
+	w.Header().Set("X-Ratelimit-Remaining", "99.0")
+	w.Header().Set("X-Ratelimit-Reset", "600")
+	return true
+}
+
+func (s *Server) handleListing(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+
+	listing, ok := strings.CutSuffix(r.PathValue("listing"), ".json")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	children := s.listings[listingKey(r.PathValue("subreddit"), listing)]
+	pageSize := s.PageSize
+	s.mu.Unlock()
+
+	query := r.URL.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 25
+	}
+	if pageSize > 0 && limit > pageSize {
+		limit = pageSize
+	}
+
+	// The after cursor is the offset into the listing, which is all a client
+	// needs to treat it as opaque.
+	offset := 0
+	if after := query.Get("after"); after != "" {
+		offset, err = strconv.Atoi(strings.TrimPrefix(after, "offset_"))
+		if err != nil {
+			http.Error(w, `{"message": "Bad Request", "error": 400}`, http.StatusBadRequest)
+			return
+		}
+	}
+	if offset > len(children) {
+		offset = len(children)
+	}
+	end := min(offset+limit, len(children))
+
+	var next string
+	if end < len(children) {
+		next = "offset_" + strconv.Itoa(end)
+	}
+
+	writeJSON(w, map[string]any{
+		"kind": "Listing",
+		"data": map[string]any{
+			"children": children[offset:end],
+			"after":    next,
+			"before":   nil,
+		},
+	})
+}
+
+func (s *Server) handleComments(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+
+	id := strings.TrimSuffix(r.PathValue("id"), ".json")
+	s.mu.Lock()
+	tree, ok := s.comments[id]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, `{"message": "Not Found", "error": 404}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(tree)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,170 @@
+// Package report builds the machine-readable summary of a pipeline run: what
+// each stage consumed and produced, which posts were dropped and why, how
+// many API calls were made and what they are estimated to cost.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/filter"
+)
+
+// Estimated list prices in USD. They are only used for the cost estimate and
+// should be checked against current pricing when budgeting.
+const (
+	// GeminiInputPrice and GeminiOutputPrice are per million tokens of Gemini
+	// 2.5 Flash. Thinking tokens are billed as output.
+	GeminiInputPrice  = 0.30
+	GeminiOutputPrice = 2.50
+	// PlacesTextSearchPrice is per Text Search request with the "*" field
+	// mask, which bills at the Enterprise + Atmosphere SKU.
+	PlacesTextSearchPrice = 0.040
+)
+
+// Report is the summary of a single run.
+type Report struct {
+	RunID           string    `json:"run_id"`
+	Command         string    `json:"command"`
+	Status          string    `json:"status"`
+	Error           string    `json:"error,omitempty"`
+	StartedAt       time.Time `json:"started_at"`
+	FinishedAt      time.Time `json:"finished_at,omitzero"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Stages          []*Stage  `json:"stages"`
+	// DropReasons counts the posts dropped by the post filter by reason;
+	// Drops lists them.
+	DropReasons      map[string]int `json:"drop_reasons,omitempty"`
+	Drops            []filter.Drop  `json:"drops,omitempty"`
+	APICalls         APICalls       `json:"api_calls"`
+	GeminiTokens     Tokens         `json:"gemini_tokens"`
+	EstimatedCostUSD float64        `json:"estimated_cost_usd"`
+}
+
+// Stage is what a single stage did. A stage served from the cache has
+// Cached set and no input count.
+type Stage struct {
+	Name            string  `json:"name"`
+	Input           int     `json:"input"`
+	Output          int     `json:"output"`
+	Failed          int     `json:"failed,omitempty"`
+	Cached          bool    `json:"cached"`
+	DurationSeconds float64 `json:"duration_seconds"`
+
+	started time.Time
+}
+
+// APICalls counts requests made to each external API, including retries.
+type APICalls struct {
+	Reddit int `json:"reddit"`
+	Gemini int `json:"gemini"`
+	Places int `json:"places"`
+}
+
+// Tokens counts Gemini tokens.
+type Tokens struct {
+	Input  int `json:"input"`
+	Output int `json:"output"`
+}
+
+// New starts the report of a run.
+func New(runID, command string) *Report {
+	return &Report{
+		RunID:     runID,
+		Command:   command,
+		StartedAt: time.Now().UTC(),
+		Stages:    []*Stage{},
+	}
+}
+
+// Stage returns the named stage, adding it if it has not been seen before.
+func (r *Report) Stage(name string) *Stage {
+	for _, stage := range r.Stages {
+		if stage.Name == name {
+			return stage
+		}
+	}
+	stage := &Stage{Name: name}
+	r.Stages = append(r.Stages, stage)
+	return stage
+}
+
+// AddDrops records posts dropped by the post filter.
+func (r *Report) AddDrops(drops []filter.Drop) {
+	if r.DropReasons == nil {
+		r.DropReasons = make(map[string]int)
+	}
+	for _, drop := range drops {
+		r.DropReasons[drop.Reason]++
+	}
+	r.Drops = append(r.Drops, drops...)
+}
+
+// Finish records the outcome of the run and computes its duration and
+// estimated cost.
+func (r *Report) Finish(status string, err error) {
+	r.Status = status
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.FinishedAt = time.Now().UTC()
+	r.DurationSeconds = seconds(r.FinishedAt.Sub(r.StartedAt))
+	for _, stage := range r.Stages {
+		// Time stages that stopped early up to the end of the run.
+		if !stage.started.IsZero() && stage.DurationSeconds == 0 {
+			stage.DurationSeconds = seconds(r.FinishedAt.Sub(stage.started))
+		}
+	}
+	r.EstimatedCostUSD = float64(r.GeminiTokens.Input)/1e6*GeminiInputPrice +
+		float64(r.GeminiTokens.Output)/1e6*GeminiOutputPrice +
+		float64(r.APICalls.Places)*PlacesTextSearchPrice
+}
+
+// Write writes the report as indented JSON.
+func (r *Report) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("error writing run report: %v", err)
+	}
+	return nil
+}
+
+// WriteFile writes the report to path.
+func (r *Report) WriteFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating run report: %v", err)
+	}
+	if err := r.Write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Start marks the stage as doing work rather than being served from the
+// cache, and starts timing it.
+func (s *Stage) Start(input int) {
+	s.Input = input
+	s.started = time.Now()
+}
+
+// Finish records the stage's output. A stage that was never started was
+// served from the cache.
+func (s *Stage) Finish(output int) {
+	s.Output = output
+	if s.started.IsZero() {
+		s.Cached = true
+		return
+	}
+	s.DurationSeconds = seconds(time.Since(s.started))
+}
+
+func seconds(d time.Duration) float64 {
+	return d.Round(time.Millisecond).Seconds()
+}
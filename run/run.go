@@ -0,0 +1,289 @@
+// Package run records the progress of pipeline runs so an interrupted or
+// failed run can be resumed where it stopped.
+//
+// Each run has an ID and a directory under DefaultDir holding a state file,
+// which lists the completed, failed and pending items of every stage, and a
+// checkpoint of the results each stage has produced so far.
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// DefaultDir is where run state is kept.
+const DefaultDir = ".cache/runs"
+
+const stateFile = "state.json"
+
+// ReportFile is the name of the run report in a run's directory; see the
+// report package.
+const ReportFile = "report.json"
+
+// Status is the outcome of a run so far.
+type Status string
+
+const (
+	StatusRunning     Status = "running"
+	StatusInterrupted Status = "interrupted"
+	StatusFailed      Status = "failed"
+	StatusCompleted   Status = "completed"
+)
+
+// State is the persisted progress of a run.
+type State struct {
+	ID      string `json:"id"`
+	Command string `json:"command"`
+	// Job is the name of the job the run belongs to, if any.
+	Job string `json:"job,omitempty"`
+	// Flags are the settings the run was started with, keyed by flag name,
+	// so a resumed run uses the same ones.
+	Flags     map[string]string `json:"flags"`
+	Status    Status            `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	StartedAt time.Time         `json:"started_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Stages    []*Stage          `json:"stages"`
+
+	dir string
+}
+
+// Stage is the progress of a single checkpointed stage. Items are identified
+// by a stable key, such as a post's permalink or a restaurant's name.
+type Stage struct {
+	Name      string    `json:"name"`
+	Completed []string  `json:"completed"`
+	Failed    []Failure `json:"failed,omitempty"`
+	Pending   []string  `json:"pending"`
+}
+
+// Failure is an item a stage could not process.
+type Failure struct {
+	Item  string `json:"item"`
+	Error string `json:"error"`
+}
+
+// IDAt returns the run ID made of the subreddit and the start time, without
+// checking that it is unused; see NewID.
+func IDAt(subreddit string, now time.Time) string {
+	return fmt.Sprintf("%s-%s", subreddit, now.UTC().Format("20060102-150405"))
+}
+
+// NewID returns an unused run ID in dir made of the subreddit and the start
+// time, and reserves it by creating the run's directory. Runs started in the
+// same second get a numbered suffix, e.g. foodnyc-20260201-090000-2.
+func NewID(dir, subreddit string, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("error creating run directory: %v", err)
+	}
+	base := IDAt(subreddit, now)
+	id := base
+	for i := 2; ; i++ {
+		err := os.Mkdir(filepath.Join(dir, id), 0755)
+		if err == nil {
+			return id, nil
+		}
+		if !os.IsExist(err) {
+			return "", fmt.Errorf("error creating run directory: %v", err)
+		}
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// New creates the state of a new run stored in dir. It is not written until
+// Save is called.
+func New(dir, id, command string, flags map[string]string) *State {
+	now := time.Now().UTC()
+	return &State{
+		ID:        id,
+		Command:   command,
+		Flags:     flags,
+		Status:    StatusRunning,
+		StartedAt: now,
+		UpdatedAt: now,
+		dir:       dir,
+	}
+}
+
+// Load reads the state of the run with the given ID from dir.
+func Load(dir, id string) (*State, error) {
+	data, err := os.ReadFile(filepath.Join(dir, id, stateFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no run with ID %q in %s", id, dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading run state: %v", err)
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("error parsing run state for %s: %v", id, err)
+	}
+	s.dir = dir
+	return &s, nil
+}
+
+// List returns the state of every run in dir, most recent first.
+func List(dir string) ([]*State, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error listing runs: %v", err)
+	}
+
+	var states []*State
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		state, err := Load(dir, entry.Name())
+		if err != nil {
+			// Skip directories that are not runs, e.g. ones being created
+			continue
+		}
+		states = append(states, state)
+	}
+	slices.SortFunc(states, func(a, b *State) int {
+		return b.StartedAt.Compare(a.StartedAt)
+	})
+	return states, nil
+}
+
+// Save writes the run state.
+func (s *State) Save() error {
+	s.UpdatedAt = time.Now().UTC()
+	return writeJSON(s.path(stateFile), s)
+}
+
+// Finish records the outcome of the run and saves it.
+func (s *State) Finish(status Status, err error) error {
+	s.Status = status
+	s.Error = ""
+	if err != nil {
+		s.Error = err.Error()
+	}
+	return s.Save()
+}
+
+// Stage returns the progress of the named stage, adding it if it has not
+// been started before.
+func (s *State) Stage(name string) *Stage {
+	for _, stage := range s.Stages {
+		if stage.Name == name {
+			return stage
+		}
+	}
+	stage := &Stage{Name: name}
+	s.Stages = append(s.Stages, stage)
+	return stage
+}
+
+// Checkpoint saves the results a stage has produced so far along with the
+// run state.
+func Checkpoint[T any](s *State, stage string, results []T) error {
+	if err := writeJSON(s.path(stage+".json"), results); err != nil {
+		return err
+	}
+	return s.Save()
+}
+
+// Results returns the results checkpointed by a stage, or nil if it has
+// none.
+func Results[T any](s *State, stage string) ([]T, error) {
+	data, err := os.ReadFile(s.path(stage + ".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s checkpoint: %v", stage, err)
+	}
+
+	var results []T
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("error parsing %s checkpoint: %v", stage, err)
+	}
+	return results, nil
+}
+
+func (s *State) path(name string) string {
+	return filepath.Join(s.Dir(), name)
+}
+
+// Start sets the items the stage has to process. Items completed by an
+// earlier attempt stay completed; everything else, including items that
+// failed before, becomes pending.
+func (st *Stage) Start(items []string) {
+	if st.Completed == nil {
+		st.Completed = []string{}
+	}
+	st.Failed = nil
+	st.Pending = []string{}
+	for _, item := range items {
+		if !st.Done(item) {
+			st.Pending = append(st.Pending, item)
+		}
+	}
+}
+
+// Done reports whether the item has been completed.
+func (st *Stage) Done(item string) bool {
+	return slices.Contains(st.Completed, item)
+}
+
+// Complete marks a pending item as completed.
+func (st *Stage) Complete(item string) {
+	st.removePending(item)
+	st.Completed = append(st.Completed, item)
+}
+
+// Fail marks a pending item as failed.
+func (st *Stage) Fail(item string, err error) {
+	st.removePending(item)
+	st.Failed = append(st.Failed, Failure{Item: item, Error: err.Error()})
+}
+
+func (st *Stage) removePending(item string) {
+	if i := slices.Index(st.Pending, item); i >= 0 {
+		st.Pending = slices.Delete(st.Pending, i, i+1)
+	}
+}
+
+// writeJSON writes v to path, replacing any existing file only once the new
+// one has been fully written.
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling run state: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating run directory: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error writing run state: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing run state: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing run state: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error writing run state: %v", err)
+	}
+	return nil
+}
+
+// Dir returns the directory holding the run's state and checkpoints.
+func (s *State) Dir() string {
+	return filepath.Join(s.dir, s.ID)
+}
@@ -0,0 +1,37 @@
+package run_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/run"
+)
+
+func TestNewIDIsUnique(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)
+
+	want := []string{"foodnyc-20260201-090000", "foodnyc-20260201-090000-2", "foodnyc-20260201-090000-3"}
+	for _, w := range want {
+		id, err := run.NewID(dir, "foodnyc", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != w {
+			t.Errorf("NewID() = %q, want %q", id, w)
+		}
+	}
+
+	// A run that was saved, not just reserved, also keeps its ID
+	other := run.New(dir, run.IDAt("other", now), "test", nil)
+	if err := other.Save(); err != nil {
+		t.Fatal(err)
+	}
+	id, err := run.NewID(dir, "other", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "other-20260201-090000-2" {
+		t.Errorf("NewID() = %q, want other-20260201-090000-2", id)
+	}
+}
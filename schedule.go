@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/tonyjhuang/reddit-to-gmap/config"
+	"github.com/tonyjhuang/reddit-to-gmap/schedule"
+)
+
+var (
+	scheduleStatePath string
+	scheduleUseCache  bool
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Run jobs from the job configuration on their cron schedules",
+	Long: `Run as a long-lived process that runs each job in the job configuration with a
+"schedule" cron expression (e.g. "0 0 1 * *") when it is due, writing its
+outputs and a run report like generate-top-post-google-map-csv. With --job,
+only that job is scheduled.
+
+The last run of each job is recorded in --state, so restarting the process
+does not run a job twice. A run that was due while the process was down is
+run once at startup, and one that was interrupted is resumed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := config.Load(configPath)
+		if err != nil {
+			return err
+		}
+		jobs := file.Jobs
+		if jobName != "" {
+			selected, err := file.Job(jobName)
+			if err != nil {
+				return err
+			}
+			jobs = []config.Job{*selected}
+		}
+
+		credentials, err := loadCredentials()
+		if err != nil {
+			return err
+		}
+		s, err := schedule.New(schedule.Config{
+			Credentials: credentials,
+			UseCache:    scheduleUseCache,
+			StatePath:   scheduleStatePath,
+		}, jobs)
+		if err != nil {
+			return err
+		}
+		return s.Run(cmd.Context())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+	scheduleCmd.Flags().StringVar(&scheduleStatePath, "state", schedule.DefaultStatePath, "Path to the file recording when each job last ran")
+	scheduleCmd.Flags().BoolVar(&scheduleUseCache, "use-cache", false, "Whether runs may start from cached data")
+}
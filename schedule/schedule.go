@@ -0,0 +1,304 @@
+// Package schedule runs jobs from the job configuration on cron expressions,
+// in-process, for hosts that run the tool as a long-lived service instead of
+// from CI.
+//
+// The time each job last ran is kept in a state file, so a restart neither
+// runs a job twice for the same scheduled time nor forgets one that was due
+// while it was down: a missed run is caught up once, at startup. A run that
+// was interrupted by a shutdown is resumed from its checkpoints.
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/tonyjhuang/reddit-to-gmap/config"
+	"github.com/tonyjhuang/reddit-to-gmap/job"
+	"github.com/tonyjhuang/reddit-to-gmap/report"
+	"github.com/tonyjhuang/reddit-to-gmap/run"
+)
+
+// DefaultStatePath is where the scheduler keeps its state when
+// Config.StatePath is not set.
+const DefaultStatePath = ".cache/schedule.json"
+
+// maxWait is the longest the scheduler sleeps before checking the clock
+// again, so that it keeps time across system suspends and clock changes.
+const maxWait = time.Minute
+
+// Config configures a Scheduler.
+type Config struct {
+	Credentials job.Credentials
+	// UseCache lets runs start from cached stage outputs.
+	UseCache bool
+	// RunDir is where run state and reports are kept. The default is
+	// run.DefaultDir.
+	RunDir string
+	// StatePath is where the last run of each job is recorded. The default
+	// is DefaultStatePath.
+	StatePath string
+}
+
+// State is the scheduler's persisted state.
+type State struct {
+	Jobs map[string]*JobState `json:"jobs"`
+}
+
+// JobState records the last run of a job.
+type JobState struct {
+	// LastRun is the scheduled time of the last run, not when it started.
+	LastRun time.Time  `json:"last_run"`
+	RunID   string     `json:"run_id,omitempty"`
+	Status  run.Status `json:"status,omitempty"`
+}
+
+// Scheduler runs jobs when their schedules are due. Runs happen one at a
+// time, so jobs due at the same time run one after another.
+type Scheduler struct {
+	config  Config
+	entries []entry
+	state   *State
+	started time.Time
+}
+
+type entry struct {
+	job      config.Job
+	schedule cron.Schedule
+}
+
+// New creates a scheduler for the jobs that have a schedule, and loads its
+// state. It fails if a schedule is invalid or no job has one.
+func New(config Config, jobs []config.Job) (*Scheduler, error) {
+	if config.RunDir == "" {
+		config.RunDir = run.DefaultDir
+	}
+	if config.StatePath == "" {
+		config.StatePath = DefaultStatePath
+	}
+
+	s := &Scheduler{config: config, started: time.Now()}
+	for _, j := range jobs {
+		if j.Schedule == "" {
+			continue
+		}
+		schedule, err := cron.ParseStandard(j.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule for job %q: %v", j.Name, err)
+		}
+		if _, err := j.Options(s.started); err != nil {
+			return nil, err
+		}
+		s.entries = append(s.entries, entry{job: j, schedule: schedule})
+	}
+	if len(s.entries) == 0 {
+		return nil, fmt.Errorf("no jobs have a schedule")
+	}
+
+	state, err := loadState(config.StatePath)
+	if err != nil {
+		return nil, err
+	}
+	s.state = state
+	return s, nil
+}
+
+// Run resumes interrupted runs, then runs jobs as they come due until ctx is
+// done. A run in progress when ctx is done is interrupted and resumed the
+// next time the scheduler starts.
+func (s *Scheduler) Run(ctx context.Context) error {
+	for _, e := range s.entries {
+		slog.Info("Scheduled job", "job", e.job.Name, "schedule", e.job.Schedule)
+	}
+	s.resume(ctx)
+
+	var announced time.Time
+	for ctx.Err() == nil {
+		e, at := s.next(time.Now())
+		wait := time.Until(at)
+		if wait <= 0 {
+			s.start(ctx, e, at)
+			continue
+		}
+		if !at.Equal(announced) {
+			slog.Info("Waiting for the next run", "job", e.job.Name, "at", at)
+			announced = at
+		}
+
+		timer := time.NewTimer(min(wait, maxWait))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+	slog.Info("Scheduler stopped")
+	return nil
+}
+
+// next returns the job that is due first and when it is due. If a job missed
+// several runs, only the latest one is due.
+func (s *Scheduler) next(now time.Time) (*entry, time.Time) {
+	var first *entry
+	var firstAt time.Time
+	for i := range s.entries {
+		e := &s.entries[i]
+		last := s.started
+		if js := s.state.Jobs[e.job.Name]; js != nil {
+			last = js.LastRun
+		}
+		at := e.schedule.Next(last)
+		for n := e.schedule.Next(at); !n.After(now); n = e.schedule.Next(n) {
+			at = n
+		}
+		if first == nil || at.Before(firstAt) {
+			first, firstAt = e, at
+		}
+	}
+	return first, firstAt
+}
+
+// resume resumes the runs that were still going when the scheduler last
+// stopped.
+func (s *Scheduler) resume(ctx context.Context) {
+	for i := range s.entries {
+		e := &s.entries[i]
+		js := s.state.Jobs[e.job.Name]
+		if js == nil || js.RunID == "" || (js.Status != run.StatusRunning && js.Status != run.StatusInterrupted) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		state, err := run.Load(s.config.RunDir, js.RunID)
+		if err != nil {
+			slog.Warn("Could not resume run", "job", e.job.Name, "run_id", js.RunID, "error", err)
+			s.record(e.job.Name, js.LastRun, js.RunID, run.StatusFailed)
+			continue
+		}
+		if state.Status == run.StatusCompleted || state.Status == run.StatusFailed {
+			// The run ended but the scheduler stopped before recording it
+			s.record(e.job.Name, js.LastRun, js.RunID, state.Status)
+			continue
+		}
+		state.Status = run.StatusRunning
+		slog.Info("Resuming run", "job", e.job.Name, "run_id", state.ID, "scheduled", js.LastRun)
+		s.execute(ctx, e, js.LastRun, state)
+	}
+}
+
+// start starts a new run of a job for the given scheduled time.
+func (s *Scheduler) start(ctx context.Context, e *entry, scheduled time.Time) {
+	id, err := run.NewID(s.config.RunDir, e.job.Subreddit, time.Now())
+	if err != nil {
+		// Record the failure so the scheduler moves on to the next run
+		slog.Error("Scheduled run failed", "job", e.job.Name, "scheduled", scheduled, "error", err)
+		s.record(e.job.Name, scheduled, "", run.StatusFailed)
+		return
+	}
+	state := run.New(s.config.RunDir, id, "schedule", e.job.Flags())
+	slog.Info("Starting scheduled run", "job", e.job.Name, "run_id", id, "scheduled", scheduled)
+	s.execute(ctx, e, scheduled, state)
+}
+
+// execute runs a job and records the run. Relative dates in the job resolve
+// against the scheduled time, so a resumed run covers the same window.
+func (s *Scheduler) execute(ctx context.Context, e *entry, scheduled time.Time, state *run.State) {
+	s.record(e.job.Name, scheduled, state.ID, run.StatusRunning)
+	if err := state.Save(); err != nil {
+		slog.Warn("Could not save run state", "run_id", state.ID, "error", err)
+	}
+
+	opts, err := e.job.Options(scheduled)
+	if err != nil {
+		slog.Error("Scheduled run failed", "job", e.job.Name, "run_id", state.ID, "error", err)
+		state.Finish(run.StatusFailed, err)
+		s.record(e.job.Name, scheduled, state.ID, run.StatusFailed)
+		return
+	}
+	opts.Credentials = s.config.Credentials
+	opts.UseCache = s.config.UseCache
+	opts.State = state
+	opts.Report = report.New(state.ID, "schedule")
+
+	result, err := job.Run(ctx, opts)
+	s.record(e.job.Name, scheduled, state.ID, result.Status)
+
+	attrs := []any{
+		"job", e.job.Name,
+		"run_id", state.ID,
+		"status", result.Status,
+		"files", result.Files,
+		"report", filepath.Join(state.Dir(), run.ReportFile),
+		"duration_seconds", result.Report.DurationSeconds,
+		"estimated_cost_usd", result.Report.EstimatedCostUSD,
+	}
+	if err != nil && result.Status == run.StatusFailed {
+		slog.Error("Scheduled run failed", append(attrs, "error", err)...)
+		return
+	}
+	slog.Info("Scheduled run finished", attrs...)
+}
+
+// record updates a job's last run and saves the state.
+func (s *Scheduler) record(name string, scheduled time.Time, runID string, status run.Status) {
+	s.state.Jobs[name] = &JobState{LastRun: scheduled, RunID: runID, Status: status}
+	if err := saveState(s.config.StatePath, s.state); err != nil {
+		slog.Warn("Could not save schedule state", "error", err)
+	}
+}
+
+// loadState reads the scheduler state, or returns an empty state if there is
+// none yet.
+func loadState(path string) (*State, error) {
+	state := &State{Jobs: make(map[string]*JobState)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading schedule state: %v", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("error parsing schedule state %s: %v", path, err)
+	}
+	if state.Jobs == nil {
+		state.Jobs = make(map[string]*JobState)
+	}
+	return state, nil
+}
+
+// saveState writes the scheduler state, replacing the existing file only
+// once the new one has been fully written.
+func saveState(path string, state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling schedule state: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating schedule state directory: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error writing schedule state: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing schedule state: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing schedule state: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error writing schedule state: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package schedule
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/config"
+	"github.com/tonyjhuang/reddit-to-gmap/run"
+)
+
+func date(t *testing.T, value string) time.Time {
+	t.Helper()
+	d, err := time.ParseInLocation("2006-01-02 15:04", value, time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// newScheduler creates a scheduler with the given saved state, as if it had
+// been started at started.
+func newScheduler(t *testing.T, saved *State, started time.Time, jobs ...config.Job) *Scheduler {
+	t.Helper()
+	statePath := filepath.Join(t.TempDir(), "schedule.json")
+	if saved != nil {
+		if err := saveState(statePath, saved); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := New(Config{RunDir: t.TempDir(), StatePath: statePath}, jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.started = started
+	return s
+}
+
+var monthly = config.Job{Name: "monthly", Subreddit: "foodnyc", Schedule: "0 0 1 * *"}
+
+func TestNextCatchesUpOnce(t *testing.T) {
+	// The scheduler was down for the February, March and April runs
+	saved := &State{Jobs: map[string]*JobState{
+		"monthly": {LastRun: date(t, "2026-01-01 00:00"), Status: run.StatusCompleted},
+	}}
+	now := date(t, "2026-04-15 12:00")
+	s := newScheduler(t, saved, now, monthly)
+
+	e, at := s.next(now)
+	if e.job.Name != "monthly" {
+		t.Fatalf("next() job = %q", e.job.Name)
+	}
+	if want := date(t, "2026-04-01 00:00"); !at.Equal(want) {
+		t.Errorf("next() = %v, want only the latest missed run, %v", at, want)
+	}
+
+	// Once that run is recorded, nothing is due until May
+	s.record("monthly", at, "foodnyc-20260415-120000", run.StatusCompleted)
+	if _, at := s.next(now); !at.Equal(date(t, "2026-05-01 00:00")) {
+		t.Errorf("next() after catching up = %v, want 2026-05-01", at)
+	}
+}
+
+func TestNextDoesNotRunTwice(t *testing.T) {
+	// The April run happened before a restart later that day
+	saved := &State{Jobs: map[string]*JobState{
+		"monthly": {LastRun: date(t, "2026-04-01 00:00"), Status: run.StatusCompleted},
+	}}
+	now := date(t, "2026-04-01 09:00")
+	s := newScheduler(t, saved, now, monthly)
+
+	if _, at := s.next(now); !at.Equal(date(t, "2026-05-01 00:00")) {
+		t.Errorf("next() = %v, want 2026-05-01", at)
+	}
+}
+
+func TestNextWithoutState(t *testing.T) {
+	// A new job waits for its first scheduled time after the scheduler
+	// started rather than catching up on earlier ones
+	now := date(t, "2026-04-15 12:00")
+	s := newScheduler(t, nil, now, monthly)
+
+	if _, at := s.next(now); !at.Equal(date(t, "2026-05-01 00:00")) {
+		t.Errorf("next() = %v, want 2026-05-01", at)
+	}
+}
+
+func TestNextPicksEarliestJob(t *testing.T) {
+	daily := config.Job{Name: "daily", Subreddit: "foodnyc", Schedule: "30 6 * * *"}
+	now := date(t, "2026-04-15 12:00")
+	s := newScheduler(t, nil, now, monthly, daily)
+
+	e, at := s.next(now)
+	if e.job.Name != "daily" || !at.Equal(date(t, "2026-04-16 06:30")) {
+		t.Errorf("next() = %q at %v, want daily at 2026-04-16 06:30", e.job.Name, at)
+	}
+}
+
+func TestStatePersists(t *testing.T) {
+	now := date(t, "2026-04-15 12:00")
+	s := newScheduler(t, nil, now, monthly)
+	s.record("monthly", date(t, "2026-04-01 00:00"), "foodnyc-20260401-000000", run.StatusCompleted)
+
+	// A restarted scheduler reads the recorded run back
+	restarted, err := New(s.config, []config.Job{monthly})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.started = now
+	js := restarted.state.Jobs["monthly"]
+	if js == nil || js.RunID != "foodnyc-20260401-000000" || js.Status != run.StatusCompleted {
+		t.Fatalf("restored state = %+v", js)
+	}
+	if _, at := restarted.next(now); !at.Equal(date(t, "2026-05-01 00:00")) {
+		t.Errorf("next() after restart = %v, want 2026-05-01", at)
+	}
+}
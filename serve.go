@@ -0,0 +1,47 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/tonyjhuang/reddit-to-gmap/server"
+)
+
+var (
+	serveAddr      string
+	serveQueueSize int
+	serveWorkers   int
+	serveMaxPosts  int
+	serveUseCache  bool
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve an HTTP API to submit jobs and fetch their results",
+	Long: `Serve an HTTP API for running the pipeline. Jobs are submitted as JSON in the
+same shape as a job in the job configuration file, queued, and run in the
+background. Their status can be polled, they can be canceled, and the results
+of completed jobs can be fetched as JSON, CSV, GeoJSON or KML. Past runs are
+listed from the run directory.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		credentials, err := loadCredentials()
+		if err != nil {
+			return err
+		}
+		s := server.New(server.Config{
+			Credentials: credentials,
+			QueueSize:   serveQueueSize,
+			Workers:     serveWorkers,
+			MaxPosts:    serveMaxPosts,
+			UseCache:    serveUseCache,
+		})
+		return s.ListenAndServe(cmd.Context(), serveAddr)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&serveAddr, "addr", ":8080", "Address to listen on")
+	serveCmd.Flags().IntVar(&serveQueueSize, "queue-size", 10, "Number of jobs that can wait to run; further submissions are rejected")
+	serveCmd.Flags().IntVar(&serveWorkers, "workers", 1, "Number of jobs to run at once")
+	serveCmd.Flags().IntVar(&serveMaxPosts, "max-posts", 1000, "Largest num_posts a job may ask for (0 means no limit)")
+	serveCmd.Flags().BoolVar(&serveUseCache, "use-cache", false, "Whether jobs may start from cached data")
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/tonyjhuang/reddit-to-gmap/config"
+	"github.com/tonyjhuang/reddit-to-gmap/job"
+	"github.com/tonyjhuang/reddit-to-gmap/run"
+)
+
+// contentTypes are the content types of the result formats.
+var contentTypes = map[string]string{
+	"json":     "application/json",
+	"csv":      "text/csv; charset=utf-8",
+	"geojson":  "application/geo+json",
+	"kml":      "application/vnd.google-earth.kml+xml",
+	"kmz":      "application/vnd.google-earth.kmz",
+	"gpx":      "application/gpx+xml",
+	"atom":     "application/atom+xml",
+	"markdown": "text/markdown; charset=utf-8",
+}
+
+// Handler returns the HTTP API:
+//
+//	POST /jobs                 submit a job; the body is a job as in the job config
+//	GET  /jobs                 list submitted jobs
+//	GET  /jobs/{id}            get a job's status
+//	POST /jobs/{id}/cancel     cancel a queued or running job
+//	GET  /jobs/{id}/results    get a completed job's restaurants; ?format=json, csv, geojson, kml, kmz, gpx, atom or markdown
+//	GET  /runs                 list past runs from the run directory
+//	GET  /runs/{id}            get a past run's state and report
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", s.handleSubmit)
+	mux.HandleFunc("GET /jobs", s.handleList)
+	mux.HandleFunc("GET /jobs/{id}", s.handleStatus)
+	mux.HandleFunc("POST /jobs/{id}/cancel", s.handleCancel)
+	mux.HandleFunc("GET /jobs/{id}/results", s.handleResults)
+	mux.HandleFunc("GET /runs", s.handleRuns)
+	mux.HandleFunc("GET /runs/{id}", s.handleRun)
+	return mux
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var request config.Job
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid job: %v", err))
+		return
+	}
+
+	j, err := s.submit(request)
+	switch {
+	case errors.Is(err, errQueueFull):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		snapshot, _ := s.job(j.ID)
+		w.Header().Set("Location", "/jobs/"+j.ID)
+		writeJSON(w, http.StatusAccepted, snapshot)
+	}
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.list())
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.job(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such job")
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	j, err := s.cancelJob(r.PathValue("id"))
+	switch {
+	case errors.Is(err, errNotFound):
+		writeError(w, http.StatusNotFound, "no such job")
+	case err != nil:
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeJSON(w, http.StatusAccepted, j)
+	}
+}
+
+func (s *Server) handleResults(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.job(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such job")
+		return
+	}
+	if j.Status != StatusCompleted {
+		writeError(w, http.StatusConflict, fmt.Sprintf("job %s is %s", j.ID, j.Status))
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if !slices.Contains(job.OutputFormats, format) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown format %q (expected one of %s)", format, strings.Join(job.OutputFormats, ", ")))
+		return
+	}
+
+	contentType, ok := contentTypes[format]
+	if !ok {
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	if err := job.WriteRestaurants(w, format, &j.options, j.results); err != nil {
+		slog.Warn("Could not write results", "job_id", j.ID, "format", format, "error", err)
+	}
+}
+
+func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	states, err := run.List(s.config.RunDir)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if states == nil {
+		states = []*run.State{}
+	}
+	writeJSON(w, http.StatusOK, states)
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id != filepath.Base(id) {
+		writeError(w, http.StatusNotFound, "no such run")
+		return
+	}
+	state, err := run.Load(s.config.RunDir, id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "no such run")
+		return
+	}
+
+	response := struct {
+		*run.State
+		Report json.RawMessage `json:"report,omitempty"`
+	}{State: state}
+	if data, err := os.ReadFile(filepath.Join(state.Dir(), run.ReportFile)); err == nil && json.Valid(data) {
+		response.Report = data
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		slog.Warn("Could not write response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
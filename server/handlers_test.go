@@ -0,0 +1,116 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tonyjhuang/reddit-to-gmap/job"
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+)
+
+// completedJob adds a job that finished without running, as a job submitted
+// over the API and run by a worker would look.
+func completedJob(t *testing.T, s *Server) string {
+	t.Helper()
+	results := []maps.Restaurant{
+		{
+			Name:      "Joe's Pizza",
+			Upvotes:   42,
+			RedditUrl: "https://www.reddit.com/r/foodnyc/comments/a1/joes/",
+			GoogleMapsData: maps.GoogleMapsData{
+				Name:          "Joe's Pizza",
+				Latitude:      40.7306,
+				Longitude:     -73.9890,
+				Rating:        4.5,
+				GoogleMapsUrl: "https://maps.google.com/?cid=1",
+				Type:          "Pizza",
+			},
+		},
+		{Name: "No Places match", Upvotes: 3},
+	}
+	id := "foodnyc-20260101-000000"
+	s.jobs[id] = &Job{
+		ID:          id,
+		Status:      StatusCompleted,
+		options:     job.Options{Subreddit: "foodnyc"},
+		results:     results,
+		Restaurants: len(results),
+	}
+	s.order = append(s.order, id)
+	return id
+}
+
+func TestHandleResultsFormats(t *testing.T) {
+	s := New(Config{RunDir: t.TempDir()})
+	id := completedJob(t, s)
+	handler := s.Handler()
+
+	for _, format := range job.OutputFormats {
+		t.Run(format, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/jobs/"+id+"/results?format="+format, nil)
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, request)
+
+			if response.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", response.Code, response.Body)
+			}
+			if got, want := response.Header().Get("Content-Type"), contentTypes[format]; got != want {
+				t.Errorf("Content-Type = %q, want %q", got, want)
+			}
+			if response.Body.Len() == 0 {
+				t.Error("empty body")
+			}
+		})
+	}
+}
+
+func TestHandleResultsUnknownFormat(t *testing.T) {
+	s := New(Config{RunDir: t.TempDir()})
+	id := completedJob(t, s)
+
+	request := httptest.NewRequest(http.MethodGet, "/jobs/"+id+"/results?format=pdf", nil)
+	response := httptest.NewRecorder()
+	s.Handler().ServeHTTP(response, request)
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", response.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleSubmitRejects(t *testing.T) {
+	tests := map[string]string{
+		"output dir": `{"subreddit": "foodnyc", "output_dir": "../../etc"}`,
+		"filename":   `{"subreddit": "foodnyc", "filename": "../x.{format}"}`,
+		"boundaries": `{"subreddit": "foodnyc", "boundaries": ["/etc/passwd"]}`,
+		"output":     `{"subreddit": "foodnyc", "stages": ["posts", "filter", "restaurants", "full_restaurants", "locate", "rank", "output"]}`,
+		"notify":     `{"subreddit": "foodnyc", "notify": {"webhooks": [{"url": "https://example.com/$HOME"}]}}`,
+		"too many":   `{"subreddit": "foodnyc", "num_posts": 500}`,
+		"window":     `{"subreddit": "foodnyc", "month": "2026-01"}`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := New(Config{RunDir: t.TempDir(), MaxPosts: 100})
+			request := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(body))
+			response := httptest.NewRecorder()
+			s.Handler().ServeHTTP(response, request)
+
+			if response.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d; body %s", response.Code, http.StatusBadRequest, response.Body)
+			}
+		})
+	}
+}
+
+func TestHandleSubmitAccepts(t *testing.T) {
+	s := New(Config{RunDir: t.TempDir(), MaxPosts: 100})
+	body := `{"subreddit": "foodnyc", "month": "2026-01", "num_posts": 100}`
+	request := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(body))
+	response := httptest.NewRecorder()
+	s.Handler().ServeHTTP(response, request)
+
+	if response.Code != http.StatusAccepted {
+		t.Errorf("status = %d, want %d; body %s", response.Code, http.StatusAccepted, response.Body)
+	}
+}
@@ -0,0 +1,318 @@
+// Package server exposes the pipeline over HTTP. Clients submit jobs, poll
+// their status, cancel them and fetch their results as JSON, CSV, GeoJSON or
+// KML. Jobs wait in a bounded queue and are run by a fixed number of workers;
+// a submission is rejected when the queue is full.
+//
+// Every job is also a run in the run directory, with its state and report,
+// so past runs can be listed even after the server restarts.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tonyjhuang/reddit-to-gmap/config"
+	"github.com/tonyjhuang/reddit-to-gmap/job"
+	"github.com/tonyjhuang/reddit-to-gmap/maps"
+	"github.com/tonyjhuang/reddit-to-gmap/report"
+	"github.com/tonyjhuang/reddit-to-gmap/run"
+)
+
+// Config configures a Server.
+type Config struct {
+	Credentials job.Credentials
+	// QueueSize is the number of jobs that can wait to run. The default is 10.
+	QueueSize int
+	// Workers is the number of jobs run at once. The default is 1.
+	Workers int
+	// MaxPosts caps the number of posts a job may ask for (0 means no cap).
+	MaxPosts int
+	// UseCache lets jobs start from cached stage outputs.
+	UseCache bool
+	// RunDir is where run state and reports are kept. The default is
+	// run.DefaultDir.
+	RunDir string
+}
+
+// Status is where a job is in its lifecycle.
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+)
+
+// Job is a submitted job. Its ID is also its run ID.
+type Job struct {
+	ID          string     `json:"id"`
+	Status      Status     `json:"status"`
+	Request     config.Job `json:"request"`
+	Error       string     `json:"error,omitempty"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+	StartedAt   time.Time  `json:"started_at,omitzero"`
+	FinishedAt  time.Time  `json:"finished_at,omitzero"`
+	// Restaurants is the number of restaurants in the results.
+	Restaurants int `json:"restaurants"`
+
+	options job.Options
+	results []maps.Restaurant
+	cancel  context.CancelFunc
+}
+
+// Server runs submitted jobs and serves the HTTP API.
+type Server struct {
+	config Config
+	queue  chan *Job
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+	// order lists job IDs in submission order.
+	order []string
+}
+
+// New creates a server. Call Start, or ListenAndServe, to begin running jobs.
+func New(config Config) *Server {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 10
+	}
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.RunDir == "" {
+		config.RunDir = run.DefaultDir
+	}
+	return &Server{
+		config: config,
+		queue:  make(chan *Job, config.QueueSize),
+		jobs:   make(map[string]*Job),
+	}
+}
+
+// Start starts the workers. They stop once ctx is done, canceling the jobs
+// they are running. The returned WaitGroup is done once they have stopped.
+func (s *Server) Start(ctx context.Context) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	for range s.config.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-s.queue:
+					s.run(ctx, j)
+				}
+			}
+		}()
+	}
+	return &wg
+}
+
+// ListenAndServe runs jobs and serves the API on addr until ctx is done, then
+// shuts down and waits for running jobs to stop.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	workers := s.Start(ctx)
+	httpServer := &http.Server{Addr: addr, Handler: s.Handler()}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("Serving the API", "addr", addr, "workers", s.config.Workers, "queue_size", s.config.QueueSize)
+	err := httpServer.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("error serving the API: %v", err)
+	}
+	workers.Wait()
+	return nil
+}
+
+// submit validates a job request and queues it.
+func (s *Server) submit(request config.Job) (*Job, error) {
+	now := time.Now()
+	opts, err := request.Options(now)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validate(request, &opts); err != nil {
+		return nil, err
+	}
+	if len(opts.Stages) == 0 {
+		// Results are served over the API rather than written to files
+		opts.Stages = job.StagesThrough(job.RankStage)
+	}
+	opts.Credentials = s.config.Credentials
+	opts.UseCache = s.config.UseCache
+
+	id, err := run.NewID(s.config.RunDir, request.Subreddit, now)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j := &Job{
+		ID:          id,
+		Status:      StatusQueued,
+		Request:     request,
+		SubmittedAt: now.UTC(),
+		options:     opts,
+	}
+
+	select {
+	case s.queue <- j:
+	default:
+		// Release the reserved run ID
+		os.Remove(filepath.Join(s.config.RunDir, id))
+		return nil, errQueueFull
+	}
+	s.jobs[id] = j
+	s.order = append(s.order, id)
+	slog.Info("Job queued", "job_id", id, "subreddit", request.Subreddit)
+	return j, nil
+}
+
+// validate rejects the settings a client may not use. Jobs only read what
+// the server is configured with and keep their results in memory, so
+// clients can't make the server read or write files of their choosing, or
+// send requests anywhere but the APIs it uses.
+func (s *Server) validate(request config.Job, opts *job.Options) error {
+	if request.Subreddit == "" {
+		return fmt.Errorf("a subreddit is required")
+	}
+	switch {
+	case len(request.Notify.Webhooks) > 0:
+		return fmt.Errorf("notify is not supported for submitted jobs")
+	case request.OutputDir != "" || request.Filename != "":
+		return fmt.Errorf("output_dir and filename are not supported for submitted jobs; fetch the results instead")
+	case len(request.Boundaries) > 0:
+		return fmt.Errorf("boundaries are not supported for submitted jobs")
+	case slices.Contains(request.Stages, job.OutputStage):
+		return fmt.Errorf("the %s stage is not supported for submitted jobs; fetch the results instead", job.OutputStage)
+	}
+
+	if s.config.MaxPosts > 0 {
+		numPosts := opts.NumPosts
+		if numPosts == 0 && opts.Since.IsZero() {
+			numPosts = job.DefaultNumPosts
+		}
+		// A date window without num_posts fetches every post in it
+		if numPosts == 0 || numPosts > s.config.MaxPosts {
+			return fmt.Errorf("num_posts is limited to %d", s.config.MaxPosts)
+		}
+	}
+	return nil
+}
+
+var errQueueFull = errors.New("the job queue is full; try again later")
+
+// run runs a queued job unless it was canceled while waiting.
+func (s *Server) run(ctx context.Context, j *Job) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mu.Lock()
+	if j.Status != StatusQueued {
+		s.mu.Unlock()
+		return
+	}
+	j.Status = StatusRunning
+	j.StartedAt = time.Now().UTC()
+	j.cancel = cancel
+	opts := j.options
+	s.mu.Unlock()
+
+	state := run.New(s.config.RunDir, j.ID, "serve", j.Request.Flags())
+	opts.State = state
+	opts.Report = report.New(j.ID, "serve")
+	if err := state.Save(); err != nil {
+		slog.Warn("Could not save run state", "job_id", j.ID, "error", err)
+	}
+	slog.Info("Job started", "job_id", j.ID)
+
+	result, err := job.Run(ctx, opts)
+
+	status := StatusCompleted
+	switch result.Status {
+	case run.StatusInterrupted:
+		status = StatusCanceled
+	case run.StatusFailed:
+		status = StatusFailed
+	}
+
+	s.mu.Lock()
+	j.Status = status
+	j.FinishedAt = time.Now().UTC()
+	if err != nil {
+		j.Error = err.Error()
+	}
+	j.results = result.Restaurants
+	// Results are written with the run's ID and report
+	j.options = opts
+	j.Restaurants = len(result.Restaurants)
+	j.cancel = nil
+	s.mu.Unlock()
+	slog.Info("Job finished", "job_id", j.ID, "status", status, "restaurants", j.Restaurants)
+}
+
+// cancelJob cancels a queued or running job.
+func (s *Server) cancelJob(id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return Job{}, errNotFound
+	}
+	switch j.Status {
+	case StatusQueued:
+		// The worker skips it when it comes off the queue
+		j.Status = StatusCanceled
+		j.FinishedAt = time.Now().UTC()
+	case StatusRunning:
+		j.cancel()
+	default:
+		return Job{}, fmt.Errorf("job %s has already %s", id, j.Status)
+	}
+	return *j, nil
+}
+
+var errNotFound = errors.New("not found")
+
+// job returns a copy of a job, safe to use without holding the lock.
+func (s *Server) job(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// list returns copies of all jobs, most recent first.
+func (s *Server) list() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Job, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0; i-- {
+		jobs = append(jobs, *s.jobs[s.order[i]])
+	}
+	return jobs
+}